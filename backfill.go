@@ -0,0 +1,210 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/go-feedparser"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Layout of dates passed on the command line.
+const dateLayout = "2006-01-02"
+
+// backfill downloads episodes of a feed which are older than the ones
+// downloaded by update. The marker of the feed is never moved
+// backwards. Paged and archived feeds (RFC 5005) are followed until
+// enough episodes have been found.
+func backfill(ctx context.Context, storage *store.Store, args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	since := flags.String("since", "", "download episodes published since DATE")
+	all := flags.Bool("all", false, "download all episodes")
+	count := flags.Int("count", 0, "number of episodes to download")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: %s backfill FEED [--since DATE | --all | --count N]\n", app.Name)
+		flags.PrintDefaults()
+	}
+
+	if len(args) <= 0 || strings.HasPrefix(args[0], "-") {
+		flags.Usage()
+		return errors.New("missing feed")
+	}
+
+	feedURL := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var sinceDate time.Time
+	if len(*since) > 0 {
+		var err error
+		if sinceDate, err = time.Parse(dateLayout, *since); err != nil {
+			return err
+		}
+	}
+
+	var modes int
+	for _, set := range []bool{len(*since) > 0, *all, *count > 0} {
+		if set {
+			modes++
+		}
+	}
+
+	if modes != 1 {
+		flags.Usage()
+		return errors.New("exactly one of --since, --all or --count is required")
+	}
+
+	if !storage.Contains(feedURL) {
+		return fmt.Errorf("%q is not subscribed", feedURL)
+	}
+
+	cast := storage.FetchFeed(ctx, feedURL)
+	if cast.Error != nil {
+		return cast.Error
+	} else if err := catcher.Identify(&cast); err != nil {
+		return err
+	}
+
+	history := catcher.History()
+	marker, err := history.Marker(cast)
+	if err != nil {
+		return err
+	}
+
+	candidates := func() ([]feedparser.Item, error) {
+		var items []feedparser.Item
+		for _, item := range cast.Feed.Items {
+			if !marker.IsZero() && item.PubDate.After(marker) {
+				continue
+			} else if !sinceDate.IsZero() && item.PubDate.Before(sinceDate) {
+				continue
+			}
+
+			items = append(items, item)
+		}
+
+		matched, err := catcher.Match(cast, items)
+		if err != nil {
+			return nil, err
+		}
+
+		items = nil
+		for _, item := range matched {
+			if !catcher.Downloaded(cast, item) {
+				items = append(items, item)
+			}
+		}
+
+		if *count > 0 && len(items) > *count {
+			items = items[0:*count]
+		}
+
+		return items, nil
+	}
+
+	page := cast
+	visited := map[string]bool{feedURL: true}
+
+	for {
+		if !sinceDate.IsZero() {
+			n := len(cast.Feed.Items)
+			if n > 0 && cast.Feed.Items[n-1].PubDate.Before(sinceDate) {
+				break
+			}
+		} else if *count > 0 {
+			items, err := candidates()
+			if err != nil {
+				return err
+			} else if len(items) >= *count {
+				break
+			}
+		}
+
+		next, err := nextPage(page)
+		if err != nil {
+			return err
+		} else if len(next) <= 0 || visited[next] {
+			break
+		}
+
+		visited[next] = true
+		if page = storage.FetchFeed(ctx, next); page.Error != nil {
+			return page.Error
+		}
+
+		cast.Feed.Items = append(cast.Feed.Items, page.Feed.Items...)
+		cast.Extension.Items = append(cast.Extension.Items, page.Extension.Items...)
+	}
+
+	items, err := candidates()
+	if err != nil {
+		return err
+	}
+
+	var newest time.Time
+	for i := len(items) - 1; i >= 0 && ctx.Err() == nil; i-- {
+		item := items[i]
+		if _, err := catcher.Download(ctx, cast, item); err != nil {
+			return err
+		}
+
+		if item.PubDate.After(newest) {
+			newest = item.PubDate
+		}
+	}
+
+	if marker.IsZero() && !newest.IsZero() {
+		return history.SetMarker(cast, newest)
+	}
+
+	return nil
+}
+
+// nextPage returns the absolute URL of the page following the given
+// page of a paged or archived feed. If there is no such page an empty
+// string is returned.
+func nextPage(page store.Podcast) (string, error) {
+	next := page.Extension.Link("next")
+	if len(next) <= 0 {
+		next = page.Extension.Link("prev-archive")
+	}
+
+	if len(next) <= 0 {
+		return "", nil
+	}
+
+	base, err := url.Parse(page.URL)
+	if err != nil {
+		return "", err
+	}
+
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/podcatcher"
+	"github.com/nmeum/cpod/store"
+	"regexp"
+)
+
+// catchup marks all current episodes of the given feed, or of all
+// feeds if no feed was given, as seen without downloading them.
+func catchup(ctx context.Context, storage *store.Store, args []string) error {
+	if len(args) > 1 {
+		return errors.New("USAGE: catchup [FEED]")
+	}
+
+	if len(args) == 1 {
+		if !storage.Contains(args[0]) {
+			return fmt.Errorf("%q is not subscribed", args[0])
+		}
+
+		return catchupFeed(storage.FetchFeed(ctx, args[0]))
+	}
+
+	for cast := range storage.Fetch(ctx) {
+		if err := catchupFeed(cast); err != nil {
+			app.Logger.Println(err)
+		}
+	}
+
+	return nil
+}
+
+// catchupFeed moves the marker of the given podcast to its most recent
+// episode. The marker is never moved backwards.
+func catchupFeed(p store.Podcast) error {
+	if p.Error != nil {
+		return p.Error
+	} else if err := catcher.Identify(&p); err != nil {
+		return err
+	}
+
+	history := catcher.History()
+	marker, err := history.Marker(p)
+	if err != nil {
+		return err
+	}
+
+	latest := marker
+	for _, item := range p.Feed.Items {
+		if item.PubDate.After(latest) {
+			latest = item.PubDate
+		}
+	}
+
+	if latest.Equal(marker) {
+		return nil
+	}
+
+	return history.SetMarker(p, latest)
+}
+
+// skip marks episodes of a feed as skipped, skipped episodes are never
+// downloaded.
+func skip(ctx context.Context, storage *store.Store, args []string) error {
+	return markSkipped(ctx, storage, args, true)
+}
+
+// unskip removes the skipped mark of episodes of a feed.
+func unskip(ctx context.Context, storage *store.Store, args []string) error {
+	return markSkipped(ctx, storage, args, false)
+}
+
+// markSkipped adds or removes the episodes of the feed whose GUID is
+// equal to the given pattern, or whose title matches the pattern as a
+// regular expression, to or from the skipped episodes of the feed.
+func markSkipped(ctx context.Context, storage *store.Store, args []string, mark bool) error {
+	if len(args) != 2 {
+		return errors.New("USAGE: skip|unskip FEED GUID|PATTERN")
+	}
+
+	feedURL, pattern := args[0], args[1]
+	if !storage.Contains(feedURL) {
+		return fmt.Errorf("%q is not subscribed", feedURL)
+	}
+
+	cast := storage.FetchFeed(ctx, feedURL)
+	if cast.Error != nil {
+		return cast.Error
+	} else if err := catcher.Identify(&cast); err != nil {
+		return err
+	}
+
+	history := catcher.History()
+	skipped, err := history.Skipped(cast)
+	if err != nil {
+		return err
+	}
+
+	// Not every GUID is a valid regex, those can only be matched as GUID.
+	re, _ := regexp.Compile(pattern)
+
+	var matched bool
+	for _, item := range cast.Feed.Items {
+		id := podcatcher.ItemID(cast, item)
+		if id != pattern && (re == nil || !re.MatchString(item.Title)) {
+			continue
+		}
+
+		matched = true
+		if mark {
+			skipped[id] = true
+		} else {
+			delete(skipped, id)
+		}
+	}
+
+	// Allow unskipping episodes which are no longer part of the feed.
+	if !mark && skipped[pattern] {
+		matched = true
+		delete(skipped, pattern)
+	}
+
+	if !matched {
+		return fmt.Errorf("no episode matches %q", pattern)
+	}
+
+	return history.SetSkipped(cast, skipped)
+}
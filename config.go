@@ -0,0 +1,156 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/nmeum/cpod/config"
+	"github.com/nmeum/cpod/directory"
+	"github.com/nmeum/cpod/gpodder"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/util"
+	"os"
+	"path/filepath"
+)
+
+// Name of the configuration file in the configuration directory.
+const configFile = "config.toml"
+
+// settings returns the settings which can be specified in the
+// configuration file. Settings with a flag are written back to the flag
+// once the configuration was loaded, other settings are read from cfg
+// directly. The default directories are those of the selected profile,
+// CPOD_DOWNLOAD_DIR only determines the default of other profiles since
+// it is shared by all of them.
+func settings() []config.Setting {
+	downloadEnv := "CPOD_DOWNLOAD_DIR"
+	if len(app.Profile) > 0 {
+		downloadEnv = ""
+	}
+
+	return []config.Setting{
+		{Key: "paths.download", Default: app.Dirs.Download, Env: downloadEnv, Flag: "download-dir"},
+		{Key: "paths.state", Default: app.Dirs.State, Flag: "state-dir"},
+		{Key: "paths.cache", Default: app.Dirs.Cache, Flag: "cache-dir"},
+		{Key: "paths.runtime", Default: app.Dirs.Runtime, Flag: "runtime-dir"},
+
+		{Key: "download.concurrency", Type: config.Int, Default: "5", Flag: "p"},
+		{Key: "download.recent", Type: config.Int, Default: "0", Flag: "r"},
+		{Key: "download.chapters", Type: config.Bool, Default: "false", Flag: "c"},
+		{Key: "download.transcripts", Type: config.Bool, Default: "false", Flag: "t"},
+
+		{Key: "feeds.move", Type: config.Bool, Default: "false", Flag: "m"},
+		{Key: "lock.wait", Type: config.Duration, Default: "0s", Flag: "w"},
+
+		{Key: "retention.keep", Type: config.Int, Default: "0"},
+		{Key: "retention.max_age", Type: config.Duration, Default: "0s"},
+
+		{Key: "http.user_agent", Default: util.UserAgent},
+		{Key: "http.timeout", Type: config.Duration, Default: "0s"},
+		{Key: "http.retries", Type: config.Int, Default: "3"},
+
+		{Key: "naming.directory", Default: "{title}"},
+		{Key: "naming.episode", Default: "{title}"},
+
+		{Key: "hooks.post_download"},
+		{Key: "hooks.post_update"},
+
+		{Key: "search.itunes_url", Default: directory.ITunesURL, Env: "CPOD_ITUNES_URL"},
+		{Key: "search.podcastindex_url", Default: directory.PodcastIndexURL, Env: "CPOD_PODCASTINDEX_URL"},
+		{Key: "search.podcastindex_key", Env: "CPOD_PODCASTINDEX_KEY"},
+		{Key: "search.podcastindex_secret", Env: "CPOD_PODCASTINDEX_SECRET", Secret: true},
+
+		{Key: "gpodder.url", Default: gpodder.DefaultURL, Env: "CPOD_GPODDER_URL"},
+		{Key: "gpodder.user", Env: "CPOD_GPODDER_USER"},
+		{Key: "gpodder.password", Env: "CPOD_GPODDER_PASSWORD", Secret: true},
+		{Key: "gpodder.device", Env: "CPOD_GPODDER_DEVICE"},
+	}
+}
+
+// Effective configuration, loaded by loadConfig.
+var cfg *config.Config
+
+// configPath returns the path of the configuration file.
+func configPath() string {
+	return filepath.Join(app.Dirs.Config, configFile)
+}
+
+// loadConfig determines the effective configuration from the parsed
+// flags, the environment and the configuration file. Flags take
+// precedence over environment variables which take precedence over
+// the configuration file.
+func loadConfig() error {
+	cfg = config.New(settings())
+	if err := cfg.LoadFile(configPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	} else if err := cfg.LoadEnv(); err != nil {
+		return err
+	} else if err := cfg.LoadFlags(flag.CommandLine); err != nil {
+		return err
+	}
+
+	for _, s := range cfg.Settings() {
+		if len(s.Flag) <= 0 {
+			continue
+		}
+
+		value := cfg.String(s.Key)
+		if s.Type == config.Duration {
+			value = cfg.Duration(s.Key).String()
+		}
+
+		if err := flag.Set(s.Flag, value); err != nil {
+			return fmt.Errorf("%s: %s", s.Key, err)
+		}
+	}
+
+	if cfg.Int("http.retries") <= 0 {
+		return errors.New("http.retries must be positive")
+	}
+
+	util.UserAgent = cfg.String("http.user_agent")
+	util.Retries = cfg.Int("http.retries")
+	util.Transport.ResponseHeaderTimeout = cfg.Duration("http.timeout")
+
+	itunes.BaseURL = cfg.String("search.itunes_url")
+	podcastIndex.BaseURL = cfg.String("search.podcastindex_url")
+	podcastIndex.Key = cfg.String("search.podcastindex_key")
+	podcastIndex.Secret = cfg.String("search.podcastindex_secret")
+
+	gpodderClient.BaseURL = cfg.String("gpodder.url")
+	gpodderClient.Username = cfg.String("gpodder.user")
+	gpodderClient.Password = cfg.String("gpodder.password")
+	gpodderClient.Device = cfg.String("gpodder.device")
+
+	return nil
+}
+
+// configCmd displays the effective configuration.
+func configCmd(ctx context.Context, args []string) error {
+	if len(args) != 1 || args[0] != "show" {
+		return errors.New("USAGE: config show")
+	}
+
+	if len(app.Profile) > 0 {
+		fmt.Printf("# profile %s\n", app.Profile)
+	}
+
+	fmt.Printf("# %s\n", configPath())
+	return cfg.Write(os.Stdout)
+}
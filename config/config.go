@@ -0,0 +1,328 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package config implements a configuration consisting of a fixed set
+// of settings. The value of a setting is taken from a flag, an
+// environment variable, a configuration file or its default, in this
+// order of precedence.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type of the value of a setting.
+type Type int
+
+const (
+	// String values, quoted in configuration files.
+	String Type = iota
+
+	// Int values, e.g. 42.
+	Int
+
+	// Bool values, either true or false.
+	Bool
+
+	// Duration values, e.g. 1h30m, see time.ParseDuration.
+	Duration
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "integer"
+	case Bool:
+		return "boolean"
+	case Duration:
+		return "duration"
+	default:
+		return "string"
+	}
+}
+
+// Source of the value of a setting.
+type Source int
+
+const (
+	// Default value of the setting.
+	Default Source = iota
+
+	// File is the configuration file.
+	File
+
+	// Env is an environment variable.
+	Env
+
+	// Flag is a command line flag.
+	Flag
+)
+
+func (s Source) String() string {
+	switch s {
+	case File:
+		return "file"
+	case Env:
+		return "env"
+	case Flag:
+		return "flag"
+	default:
+		return "default"
+	}
+}
+
+// Setting describes a configurable value.
+type Setting struct {
+	// Key of the setting in the configuration file, consisting of the
+	// table and the key within the table, e.g. http.timeout.
+	Key string
+
+	// Type of the value.
+	Type Type
+
+	// Default value.
+	Default string
+
+	// Name of the environment variable, empty if the setting can't be
+	// specified in the environment.
+	Env string
+
+	// Name of the command line flag, empty if the setting can't be
+	// specified as a flag.
+	Flag string
+
+	// Whether the value is confidential and shouldn't be displayed.
+	Secret bool
+}
+
+// Value is the value of a setting and its origin.
+type Value struct {
+	// Value in its textual representation.
+	Value string
+
+	// Source the value was taken from.
+	Source Source
+
+	// Origin of the value within its source, e.g. the name of the
+	// environment variable or the line of the configuration file.
+	Origin string
+}
+
+// Config contains the effective values of a fixed set of settings.
+type Config struct {
+	settings []Setting
+	values   map[string]Value
+}
+
+// New returns a configuration of the given settings using their
+// default values.
+func New(settings []Setting) *Config {
+	c := &Config{settings, make(map[string]Value)}
+	for _, s := range settings {
+		c.values[s.Key] = Value{Value: s.Default, Source: Default}
+	}
+
+	return c
+}
+
+// Settings returns all settings of the configuration.
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
+
+// Lookup returns the setting with the given key.
+func (c *Config) Lookup(key string) (Setting, bool) {
+	for _, s := range c.settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+
+	return Setting{}, false
+}
+
+// Set changes the value of the setting with the given key, unless its
+// current value was taken from a source with higher precedence. An
+// error is returned if the setting doesn't exist or the value is
+// invalid.
+func (c *Config) Set(key string, value Value) error {
+	s, ok := c.Lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	} else if err := validate(s.Type, value.Value); err != nil {
+		return fmt.Errorf("invalid value for %s: %s", key, err)
+	}
+
+	if value.Source >= c.values[key].Source {
+		c.values[key] = value
+	}
+
+	return nil
+}
+
+// Get returns the value of the setting with the given key.
+func (c *Config) Get(key string) Value {
+	return c.values[key]
+}
+
+// LoadFile reads the settings from the configuration file at the given
+// path. Unknown settings are rejected.
+func (c *Config) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	entries, err := Parse(file)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	for _, e := range entries {
+		origin := fmt.Sprintf("%s:%d", path, e.Line)
+		if err := c.Set(e.Key, Value{e.Value, File, origin}); err != nil {
+			return fmt.Errorf("%s: %s", origin, err)
+		}
+	}
+
+	return nil
+}
+
+// LoadEnv reads the settings from their environment variables, empty
+// variables are ignored.
+func (c *Config) LoadEnv() error {
+	for _, s := range c.settings {
+		if len(s.Env) <= 0 {
+			continue
+		}
+
+		value := os.Getenv(s.Env)
+		if len(value) <= 0 {
+			continue
+		}
+
+		if err := c.Set(s.Key, Value{value, Env, s.Env}); err != nil {
+			return fmt.Errorf("%s: %s", s.Env, err)
+		}
+	}
+
+	return nil
+}
+
+// LoadFlags reads the settings from the flags of the given flag set
+// which were specified on the command line.
+func (c *Config) LoadFlags(flags *flag.FlagSet) error {
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range c.settings {
+			if s.Flag == f.Name && err == nil {
+				err = c.Set(s.Key, Value{f.Value.String(), Flag, "-" + f.Name})
+			}
+		}
+	})
+
+	return err
+}
+
+// String returns the value of the setting with the given key.
+func (c *Config) String(key string) string {
+	return c.values[key].Value
+}
+
+// Int returns the value of the integer setting with the given key.
+func (c *Config) Int(key string) int {
+	i, _ := strconv.Atoi(c.values[key].Value)
+	return i
+}
+
+// Bool returns the value of the boolean setting with the given key.
+func (c *Config) Bool(key string) bool {
+	b, _ := strconv.ParseBool(c.values[key].Value)
+	return b
+}
+
+// Duration returns the value of the duration setting with the given
+// key.
+func (c *Config) Duration(key string) time.Duration {
+	d, _ := time.ParseDuration(c.values[key].Value)
+	return d
+}
+
+// Write writes the effective configuration to the given writer in the
+// format of the configuration file. The source of each value is noted
+// in a comment, values of secret settings are masked.
+func (c *Config) Write(w io.Writer) error {
+	var table string
+	for _, s := range c.settings {
+		v := c.values[s.Key]
+
+		name := s.Key
+		if i := strings.LastIndex(s.Key, "."); i >= 0 {
+			if t := s.Key[0:i]; t != table {
+				table = t
+				if _, err := fmt.Fprintf(w, "\n[%s]\n", table); err != nil {
+					return err
+				}
+			}
+
+			name = s.Key[i+1:]
+		}
+
+		value := v.Value
+		if s.Secret && len(value) > 0 {
+			value = "********"
+		}
+		if s.Type == String || s.Type == Duration {
+			value = strconv.Quote(value)
+		}
+
+		source := v.Source.String()
+		if len(v.Origin) > 0 {
+			source += " " + v.Origin
+		}
+
+		if _, err := fmt.Fprintf(w, "%s = %s # %s\n", name, value, source); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks whether the given value is valid for the given type.
+func validate(t Type, value string) error {
+	var err error
+	switch t {
+	case Int:
+		_, err = strconv.Atoi(value)
+	case Bool:
+		_, err = strconv.ParseBool(value)
+	case Duration:
+		_, err = time.ParseDuration(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%q is not a valid %s", value, t)
+	}
+
+	return nil
+}
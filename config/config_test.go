@@ -0,0 +1,130 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSettings = []Setting{
+	{Key: "download.concurrency", Type: Int, Default: "5", Flag: "p"},
+	{Key: "download.chapters", Type: Bool, Default: "false", Env: "TEST_CHAPTERS"},
+	{Key: "http.user_agent", Type: String, Default: "cpod", Env: "TEST_USER_AGENT", Flag: "u"},
+	{Key: "http.timeout", Type: Duration, Default: "0s"},
+	{Key: "http.retries", Type: Int, Default: "3"},
+	{Key: "hooks.post_download", Type: String, Secret: true},
+}
+
+func TestPrecedence(t *testing.T) {
+	c := New(testSettings)
+	if err := c.LoadFile("testdata/testParse.toml"); err == nil {
+		t.Fatal("Expected error for unknown setting")
+	}
+
+	os.Setenv("TEST_USER_AGENT", "env")
+	defer os.Unsetenv("TEST_USER_AGENT")
+
+	c = New(testSettings)
+	entries := "[download]\nconcurrency = 10\n[http]\nuser_agent = \"file\"\ntimeout = \"1m\"\n"
+	parsed, err := Parse(strings.NewReader(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range parsed {
+		if err := c.Set(e.Key, Value{e.Value, File, "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.LoadEnv(); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Int("p", 5, "")
+	flags.String("u", "cpod", "")
+	if err := flags.Parse([]string{"-p", "2"}); err != nil {
+		t.Fatal(err)
+	} else if err := c.LoadFlags(flags); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := c.Int("download.concurrency"); n != 2 {
+		t.Fatalf("Expected %d - got %d", 2, n)
+	} else if v := c.Get("download.concurrency"); v.Source != Flag || v.Origin != "-p" {
+		t.Fatalf("Expected %q - got %q", "flag -p", v.Source.String()+" "+v.Origin)
+	}
+
+	if ua := c.String("http.user_agent"); ua != "env" {
+		t.Fatalf("Expected %q - got %q", "env", ua)
+	} else if d := c.Duration("http.timeout"); d != time.Minute {
+		t.Fatalf("Expected %v - got %v", time.Minute, d)
+	} else if n := c.Int("http.retries"); n != 3 {
+		t.Fatalf("Expected %d - got %d", 3, n)
+	}
+
+	// A value of lower precedence doesn't overwrite the flag.
+	if err := c.Set("download.concurrency", Value{"7", File, "test"}); err != nil {
+		t.Fatal(err)
+	} else if n := c.Int("download.concurrency"); n != 2 {
+		t.Fatalf("Expected %d - got %d", 2, n)
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	c := New(testSettings)
+	if err := c.Set("download.concurrency", Value{"many", File, ""}); err == nil {
+		t.Fatal("Expected error for invalid integer")
+	} else if err := c.Set("http.timeout", Value{"soon", File, ""}); err == nil {
+		t.Fatal("Expected error for invalid duration")
+	} else if err := c.Set("unknown", Value{"1", File, ""}); err == nil {
+		t.Fatal("Expected error for unknown setting")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c := New(testSettings)
+	if err := c.Set("hooks.post_download", Value{"secret", Env, "TEST"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := c.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+[download]
+concurrency = 5 # default
+chapters = false # default
+
+[http]
+user_agent = "cpod" # default
+timeout = "0s" # default
+retries = 3 # default
+
+[hooks]
+post_download = "********" # env TEST
+`
+	if b.String() != expected {
+		t.Fatalf("Expected %q - got %q", expected, b.String())
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Entry is a key value pair of a configuration file.
+type Entry struct {
+	// Key including the name of its table, e.g. http.timeout.
+	Key string
+
+	// Value with quotes and escape sequences of strings removed.
+	Value string
+
+	// Line number of the entry.
+	Line int
+}
+
+// Parse parses a configuration file written in a subset of TOML. It
+// supports tables, bare keys, basic and literal strings on a single
+// line, integers, booleans and comments.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var table string
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+
+	for lnum := 1; scanner.Scan(); lnum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) <= 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 || !comment(line[end+1:]) {
+				return nil, fmt.Errorf("line %d: invalid table", lnum)
+			}
+
+			table = strings.TrimSpace(line[1:end])
+			if !bareKey(table) {
+				return nil, fmt.Errorf("line %d: invalid table name %q", lnum, table)
+			}
+
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", lnum)
+		}
+
+		key := strings.TrimSpace(line[0:i])
+		if !bareKey(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", lnum, key)
+		} else if len(table) > 0 {
+			key = table + "." + key
+		}
+
+		value, err := parseValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lnum, err)
+		} else if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lnum, key)
+		}
+
+		seen[key] = true
+		entries = append(entries, Entry{key, value, lnum})
+	}
+
+	return entries, scanner.Err()
+}
+
+// parseValue parses the value of a key value pair, the remainder of the
+// line may only contain a comment.
+func parseValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "\""):
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				if !comment(s[i+1:]) {
+					break
+				}
+
+				value, err := strconv.Unquote(s[0 : i+1])
+				if err != nil {
+					return "", fmt.Errorf("invalid string %s", s[0:i+1])
+				}
+
+				return value, nil
+			}
+		}
+
+		return "", fmt.Errorf("invalid string %s", s)
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 || !comment(s[end+2:]) {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+
+		return s[1 : end+1], nil
+	}
+
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = strings.TrimSpace(s[0:i])
+	}
+
+	if len(s) <= 0 {
+		return "", fmt.Errorf("missing value")
+	}
+
+	for _, r := range s {
+		if !strings.ContainsRune("+-_.:", r) && !isAlnum(r) {
+			return "", fmt.Errorf("invalid value %q", s)
+		}
+	}
+
+	return strings.Replace(s, "_", "", -1), nil
+}
+
+// comment returns true if the given remainder of a line is empty or a
+// comment.
+func comment(s string) bool {
+	s = strings.TrimSpace(s)
+	return len(s) <= 0 || strings.HasPrefix(s, "#")
+}
+
+// bareKey returns true if the given string is a valid bare key, tables
+// may consist of multiple bare keys separated by dots.
+func bareKey(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if len(part) <= 0 {
+			return false
+		}
+
+		for _, r := range part {
+			if r != '-' && r != '_' && !isAlnum(r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func isAlnum(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
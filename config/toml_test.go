@@ -0,0 +1,76 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	file, err := os.Open("testdata/testParse.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	entries, err := Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Entry{
+		{"top", "level", 2},
+		{"download.concurrency", "10", 5},
+		{"download.chapters", "true", 6},
+		{"http.user_agent", `cpod "test"`, 9},
+		{"http.timeout", "30s", 10},
+		{"http.retries", "1000", 11},
+		{"hooks.post_download", `notify-send "$CPOD_EPISODE"`, 14},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries - got %d", len(expected), len(entries))
+	}
+
+	for i, e := range expected {
+		if entries[i] != e {
+			t.Fatalf("Expected %v - got %v", e, entries[i])
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"key",
+		"key = ",
+		"key = \"unterminated",
+		"key = 'unterminated",
+		"key = \"value\" trailing",
+		"key = bare word",
+		"[table",
+		"[]",
+		"a b = 1",
+		"key = 1\nkey = 2",
+	}
+
+	for _, test := range tests {
+		if _, err := Parse(strings.NewReader(test)); err == nil {
+			t.Fatalf("Expected error for %q", test)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/store"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// Interval in which the URL file is checked for modifications.
+	pollInterval = 30 * time.Second
+
+	// Maximum refresh interval requested by a feed which is honoured.
+	maxHintInterval = 24 * time.Hour
+)
+
+// schedule maps feed URLs to the time they should be refreshed next.
+type schedule map[string]time.Time
+
+// daemon keeps running and refreshes feeds periodically. The URL file
+// is reloaded when it was modified or SIGHUP was received. The
+// database lock is only held while feeds are refreshed. Optionally, an
+// HTTP server providing an API and web interface is started, WebSub
+// hubs are subscribed to and RSS feeds of the downloaded episodes are
+// written after each refresh.
+func daemon(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	interval := flags.Duration("i", time.Hour, "default interval between feed refreshes")
+	listen := flags.String("l", "", "address the HTTP server listens on")
+	base := flags.String("b", "", "write RSS feeds using the given base URL after each refresh")
+	public := flags.String("u", "", "public URL of the HTTP server, enables WebSub")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: %s daemon [-i INTERVAL] [-l ADDRESS] [-b URL] [-u URL]\n", app.Name)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if *interval <= 0 {
+		return fmt.Errorf("invalid interval %v", *interval)
+	} else if len(*public) > 0 && len(*listen) <= 0 {
+		return errors.New("WebSub requires the HTTP server")
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var push *pusher
+	var pushed <-chan string
+	if len(*public) > 0 {
+		push = newPusher(strings.TrimSuffix(*public, "/") + "/websub/")
+		pushed = push.pushed
+	}
+
+	trigger := make(chan bool, 1)
+	if len(*listen) > 0 {
+		ln, err := net.Listen("tcp", *listen)
+		if err != nil {
+			return err
+		}
+
+		srv := &http.Server{Handler: newServer(trigger, push)}
+		go srv.Serve(ln)
+		defer srv.Close()
+	}
+
+	next := make(schedule)
+	for ctx.Err() == nil {
+		mtime := modTime(app.StorePath)
+		err := app.WithLock(func(storage *store.Store) error {
+			return refresh(ctx, storage, next, *interval, push)
+		})
+		if err != nil {
+			app.Logger.Println(err)
+		}
+
+		// Options stored by the refresh itself, e.g. the identifiers
+		// of new feeds, don't require another refresh.
+		if t := modTime(app.StorePath); t.Equal(app.SavedTime()) {
+			mtime = t
+		}
+
+		if len(*base) > 0 {
+			if err := writeFeeds(*base); err != nil {
+				app.Logger.Println(err)
+			}
+		}
+
+		activity.reschedule(next)
+
+		wake := next.earliest(time.Now().Add(*interval))
+		if backoff := time.Now().Add(pollInterval); err != nil && wake.Before(backoff) {
+			wake = backoff // Don't retry a failed refresh immediately
+		}
+	wait:
+		for time.Now().Before(wake) {
+			timeout := wake.Sub(time.Now())
+			if timeout > pollInterval {
+				timeout = pollInterval
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-hup:
+				break wait
+			case <-trigger:
+				next = make(schedule) // Refresh all feeds
+				break wait
+			case url := <-pushed:
+				next[url] = time.Now()
+				break wait
+			case <-time.After(timeout):
+				if !modTime(app.StorePath).Equal(mtime) {
+					break wait
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// refresh updates all feeds of the store which are due and schedules
+// their next refresh. Feeds which are no longer part of the store are
+// removed from the schedule, new feeds are refreshed immediately. If
+// WebSub is enabled, the hubs of the refreshed feeds are subscribed.
+func refresh(ctx context.Context, storage *store.Store, next schedule, interval time.Duration, push *pusher) error {
+	urls := storage.URLs()
+	subscribed := make(map[string]bool)
+
+	var due []string
+	now := time.Now()
+
+	for _, url := range urls {
+		subscribed[url] = true
+		if t, ok := next[url]; !ok || !t.After(now) {
+			due = append(due, url)
+		}
+	}
+
+	for url := range next {
+		if !subscribed[url] {
+			delete(next, url)
+		}
+	}
+	push.prune(ctx, subscribed)
+
+	if len(due) <= 0 {
+		return nil
+	}
+
+	subset := storage.Subset(due)
+	for _, url := range due {
+		next[url] = now.Add(jitter(interval))
+	}
+
+	for _, p := range catcher.UpdateStore(ctx, subset) {
+		d, err := feedInterval(p, interval)
+		if err != nil {
+			app.Logger.Println(err)
+			continue
+		}
+
+		next[p.URL] = now.Add(jitter(d))
+
+		// Renew subscriptions before they expire, even if this
+		// feed isn't refreshed for some time.
+		if err := push.subscribe(ctx, p, 2*d); err != nil {
+			app.Logger.Println(err)
+		}
+	}
+
+	return nil
+}
+
+// feedInterval returns the refresh interval of the given podcast. The
+// interval option of the feed takes precedence, otherwise the refresh
+// interval requested by the feed is used if it is longer than the
+// default interval.
+func feedInterval(p store.Podcast, def time.Duration) (time.Duration, error) {
+	if opt, ok := p.Options["interval"]; ok {
+		d, err := time.ParseDuration(opt)
+		if err != nil || d <= 0 {
+			return def, fmt.Errorf("%s: invalid interval option %q", p.URL, opt)
+		}
+
+		return d, nil
+	}
+
+	hint := p.Extension.TTL
+	if hint > maxHintInterval {
+		hint = maxHintInterval
+	}
+
+	if hint > def {
+		return hint, nil
+	}
+
+	return def, nil
+}
+
+// jitter randomly changes the given duration by up to 10 percent to
+// avoid refreshing all feeds at the same time.
+func jitter(d time.Duration) time.Duration {
+	max := int64(d / 5)
+	if max <= 0 {
+		return d
+	}
+
+	return d - d/10 + time.Duration(rand.Int63n(max))
+}
+
+// earliest returns the earliest scheduled time or the given fallback
+// if it is earlier than all scheduled times.
+func (s schedule) earliest(fallback time.Time) time.Time {
+	earliest := fallback
+	for _, t := range s {
+		if t.Before(earliest) {
+			earliest = t
+		}
+	}
+
+	return earliest
+}
+
+// modTime returns the modification time of the file at the given path
+// or the zero time if it doesn't exist.
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return fi.ModTime()
+}
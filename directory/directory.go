@@ -0,0 +1,209 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package directory implements clients for podcast directories, they
+// are used to search podcasts and to resolve their feed URLs.
+// See also: https://performance-partners.apple.com/search-api
+// and https://podcastindex-org.github.io/docs-api/
+package directory
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nmeum/cpod/util"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Default base URL of the iTunes Search API.
+	ITunesURL = "https://itunes.apple.com"
+
+	// Default base URL of the Podcast Index API.
+	PodcastIndexURL = "https://api.podcastindex.org/api/1.0"
+)
+
+// Regex matching the identifier in Apple Podcasts URLs.
+var appleID = regexp.MustCompile(`/id([0-9]+)(?:/|$)`)
+
+// Podcast represents a podcast listed in a directory.
+type Podcast struct {
+	// Title of the podcast.
+	Title string
+
+	// Author of the podcast.
+	Author string
+
+	// URL of the podcast feed.
+	URL string
+}
+
+// Directory represents a searchable podcast directory.
+type Directory interface {
+	// Search returns the podcasts matching the given term.
+	Search(ctx context.Context, term string) ([]Podcast, error)
+}
+
+// ITunes is a client for the iTunes Search API.
+type ITunes struct {
+	// Base URL of the API.
+	BaseURL string
+}
+
+// PodcastIndex is a client for the Podcast Index API.
+type PodcastIndex struct {
+	// Base URL of the API.
+	BaseURL string
+
+	// API key and secret.
+	Key, Secret string
+}
+
+// itunesResults represents the reply of the iTunes Search API.
+type itunesResults struct {
+	Results []struct {
+		CollectionName string `json:"collectionName"`
+		ArtistName     string `json:"artistName"`
+		FeedURL        string `json:"feedUrl"`
+	} `json:"results"`
+}
+
+// indexResults represents the reply of a Podcast Index search.
+type indexResults struct {
+	Feeds []struct {
+		Title  string `json:"title"`
+		Author string `json:"author"`
+		URL    string `json:"url"`
+	} `json:"feeds"`
+}
+
+// Search returns the podcasts matching the given term.
+func (d ITunes) Search(ctx context.Context, term string) ([]Podcast, error) {
+	query := url.Values{"media": {"podcast"}, "term": {term}}
+	return d.query(ctx, "/search?"+query.Encode())
+}
+
+// Lookup returns the podcast with the given iTunes identifier.
+func (d ITunes) Lookup(ctx context.Context, id string) (Podcast, error) {
+	podcasts, err := d.query(ctx, "/lookup?"+url.Values{"id": {id}}.Encode())
+	if err != nil {
+		return Podcast{}, err
+	} else if len(podcasts) <= 0 {
+		return Podcast{}, fmt.Errorf("no podcast with iTunes ID %q", id)
+	}
+
+	return podcasts[0], nil
+}
+
+func (d ITunes) query(ctx context.Context, path string) ([]Podcast, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(d.BaseURL, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results itunesResults
+	if err := getJSON(req, &results); err != nil {
+		return nil, err
+	}
+
+	var podcasts []Podcast
+	for _, r := range results.Results {
+		// Not all results are podcasts with a public feed.
+		if len(r.FeedURL) > 0 {
+			podcasts = append(podcasts, Podcast{r.CollectionName, r.ArtistName, r.FeedURL})
+		}
+	}
+
+	return podcasts, nil
+}
+
+// Search returns the podcasts matching the given term.
+func (d PodcastIndex) Search(ctx context.Context, term string) ([]Podcast, error) {
+	if len(d.Key) <= 0 || len(d.Secret) <= 0 {
+		return nil, errors.New("Podcast Index API key and secret are required")
+	}
+
+	uri := strings.TrimSuffix(d.BaseURL, "/") + "/search/byterm?" + url.Values{"q": {term}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	date := strconv.FormatInt(time.Now().Unix(), 10)
+	hash := sha1.Sum([]byte(d.Key + d.Secret + date))
+
+	req.Header.Set("X-Auth-Date", date)
+	req.Header.Set("X-Auth-Key", d.Key)
+	req.Header.Set("Authorization", hex.EncodeToString(hash[:]))
+
+	var results indexResults
+	if err := getJSON(req, &results); err != nil {
+		return nil, err
+	}
+
+	var podcasts []Podcast
+	for _, f := range results.Feeds {
+		podcasts = append(podcasts, Podcast{f.Title, f.Author, f.URL})
+	}
+
+	return podcasts, nil
+}
+
+// AppleID returns the iTunes identifier of the podcast whose Apple
+// Podcasts page is located at the given URL. If the URL doesn't point
+// to such a page, false is returned.
+func AppleID(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(u.Hostname()) {
+	case "podcasts.apple.com", "itunes.apple.com":
+	default:
+		return "", false
+	}
+
+	match := appleID.FindStringSubmatch(u.Path)
+	if match == nil {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+// getJSON performs the given request and decodes the JSON reply into
+// the value pointed to by v.
+func getJSON(req *http.Request, v interface{}) error {
+	resp, err := util.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL.Host, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,130 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package directory
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var expected = Podcast{
+	Title:  "This American Life",
+	Author: "This American Life",
+	URL:    "https://www.thisamericanlife.org/podcast/rss.xml",
+}
+
+func TestITunesSearch(t *testing.T) {
+	th := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("term") != "american life" {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeFile(w, r, "testdata/testSearch.json")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(th))
+	defer ts.Close()
+
+	podcasts, err := ITunes{ts.URL}.Search(context.Background(), "american life")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(podcasts) != 1 || podcasts[0] != expected {
+		t.Fatalf("Expected %v - got %v", []Podcast{expected}, podcasts)
+	}
+}
+
+func TestITunesLookup(t *testing.T) {
+	th := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lookup" || r.URL.Query().Get("id") != "201671138" {
+			w.Write([]byte(`{"resultCount": 0, "results": []}`))
+			return
+		}
+
+		http.ServeFile(w, r, "testdata/testSearch.json")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(th))
+	defer ts.Close()
+
+	podcast, err := ITunes{ts.URL}.Lookup(context.Background(), "201671138")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if podcast != expected {
+		t.Fatalf("Expected %v - got %v", expected, podcast)
+	}
+
+	if _, err := (ITunes{ts.URL}).Lookup(context.Background(), "42"); err == nil {
+		t.Fatal("Expected error for unknown podcast")
+	}
+}
+
+func TestPodcastIndexSearch(t *testing.T) {
+	th := func(w http.ResponseWriter, r *http.Request) {
+		hash := sha1.Sum([]byte("key" + "secret" + r.Header.Get("X-Auth-Date")))
+		if r.Header.Get("X-Auth-Key") != "key" || r.Header.Get("Authorization") != hex.EncodeToString(hash[:]) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		http.ServeFile(w, r, "testdata/testByTerm.json")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(th))
+	defer ts.Close()
+
+	podcasts, err := PodcastIndex{ts.URL, "key", "secret"}.Search(context.Background(), "american life")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(podcasts) != 1 || podcasts[0] != expected {
+		t.Fatalf("Expected %v - got %v", []Podcast{expected}, podcasts)
+	}
+
+	if _, err := (PodcastIndex{ts.URL, "key", "wrong"}).Search(context.Background(), "x"); err == nil {
+		t.Fatal("Expected error for invalid credentials")
+	}
+}
+
+func TestAppleID(t *testing.T) {
+	tests := []struct {
+		uri string
+		id  string
+		ok  bool
+	}{
+		{"https://podcasts.apple.com/us/podcast/this-american-life/id201671138", "201671138", true},
+		{"https://podcasts.apple.com/us/podcast/id201671138?i=1000", "201671138", true},
+		{"https://itunes.apple.com/us/podcast/foo/id42/", "42", true},
+		{"https://example.com/podcast/id201671138", "", false},
+		{"https://podcasts.apple.com/us/browse", "", false},
+	}
+
+	for _, test := range tests {
+		id, ok := AppleID(test.uri)
+		if id != test.id || ok != test.ok {
+			t.Fatalf("Expected %q, %v - got %q, %v", test.id, test.ok, id, ok)
+		}
+	}
+}
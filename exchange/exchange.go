@@ -0,0 +1,143 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package exchange implements import and export of subscription lists
+// in the formats used by other podcast clients and services.
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Subscription represents a subscribed feed.
+type Subscription struct {
+	// URL of the feed.
+	URL string `json:"url"`
+
+	// Title of the feed, might be empty.
+	Title string `json:"title,omitempty"`
+
+	// URL of the website the feed belongs to, might be empty.
+	Link string `json:"link,omitempty"`
+
+	// Slash delimited paths of the folders or categories of the feed.
+	Categories []string `json:"categories,omitempty"`
+
+	// Feed options as stored in the cpod URL file.
+	Options map[string]string `json:"options,omitempty"`
+
+	// Episodes the user interacted with, e.g. played episodes.
+	Episodes []Episode `json:"episodes,omitempty"`
+}
+
+// Episode represents the state of an episode of a subscription.
+type Episode struct {
+	// URL of the episode file.
+	URL string `json:"url"`
+
+	// Title of the episode, might be empty.
+	Title string `json:"title,omitempty"`
+
+	// Time the episode was published, might be zero.
+	Published time.Time `json:"published,omitempty"`
+
+	// Whether the episode was played completely.
+	Played bool `json:"played,omitempty"`
+}
+
+// Importer reads subscriptions from a subscription list.
+type Importer interface {
+	// Import returns the subscriptions read from the given reader.
+	Import(r io.Reader) ([]Subscription, error)
+}
+
+// Exporter writes subscriptions to a subscription list.
+type Exporter interface {
+	// Export writes the given subscriptions to the given writer.
+	Export(w io.Writer, subs []Subscription) error
+}
+
+// Format is a subscription list format supporting import and export.
+type Format interface {
+	Importer
+	Exporter
+}
+
+// Supported formats by name.
+var formats = map[string]Format{
+	"opml":        OPML{},
+	"antennapod":  AntennaPod{},
+	"pocketcasts": PocketCasts{},
+	"overcast":    Overcast{},
+	"txt":         URLList{},
+	"json":        JSON{},
+	"gpodder":     GPodder{},
+}
+
+// Lookup returns the format with the given name.
+func Lookup(name string) (Format, error) {
+	f, ok := formats[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, supported: %s", name, strings.Join(Names(), ", "))
+	}
+
+	return f, nil
+}
+
+// Names returns the sorted names of all supported formats.
+func Names() []string {
+	var names []string
+	for name := range formats {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// InCategory returns true if the subscription is part of the folder or
+// category with the given name or path, including nested ones. Names
+// are compared case-insensitively.
+func (s Subscription) InCategory(name string) bool {
+	name = strings.ToLower(strings.Trim(name, "/"))
+	for _, path := range s.Categories {
+		path = strings.ToLower(path)
+		if path == name || strings.HasPrefix(path, name+"/") {
+			return true
+		}
+
+		for _, elem := range strings.Split(path, "/") {
+			if elem == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// text returns the title of the subscription or its URL if the title
+// is unknown.
+func (s Subscription) text() string {
+	if len(s.Title) > 0 {
+		return s.Title
+	}
+
+	return s.URL
+}
@@ -0,0 +1,240 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package exchange
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func importFile(t *testing.T, f Importer, path string) []Subscription {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	subs, err := f.Import(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return subs
+}
+
+func TestLookup(t *testing.T) {
+	f, err := Lookup("OPML")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := f.(OPML); !ok {
+		t.Fatalf("Expected %T - got %T", OPML{}, f)
+	}
+
+	if _, err := Lookup("foo"); err == nil {
+		t.Fatal("Expected error for unknown format")
+	}
+}
+
+func TestImportOPML(t *testing.T) {
+	subs := importFile(t, OPML{}, "../opml/testdata/testNested.opml")
+	if len(subs) != 3 {
+		t.Fatalf("Expected %d - got %d", 3, len(subs))
+	}
+
+	expected := Subscription{
+		URL:        "http://example.com/daily.rss",
+		Title:      "The Daily",
+		Link:       "http://example.com/",
+		Categories: []string{"News"},
+	}
+
+	if !reflect.DeepEqual(subs[0], expected) {
+		t.Fatalf("Expected %v - got %v", expected, subs[0])
+	}
+
+	categories := []string{"Local", "News/Politics", "News/Local"}
+	if !reflect.DeepEqual(subs[1].Categories, categories) {
+		t.Fatalf("Expected %q - got %q", categories, subs[1].Categories)
+	}
+
+	if !subs[1].InCategory("news") || !subs[1].InCategory("news/local") {
+		t.Fatalf("Expected %q to be in category", subs[1].URL)
+	}
+
+	if subs[2].InCategory("News") {
+		t.Fatalf("Expected %q not to be in category", subs[2].URL)
+	}
+}
+
+func TestImportAntennaPod(t *testing.T) {
+	subs := importFile(t, AntennaPod{}, "testdata/testAntennaPod.opml")
+	if len(subs) != 2 {
+		t.Fatalf("Expected %d - got %d", 2, len(subs))
+	}
+
+	if subs[1].URL != "http://example.org/feed.rss" {
+		t.Fatalf("Expected %q - got %q", "http://example.org/feed.rss", subs[1].URL)
+	}
+
+	if subs[1].Title != "Other Show" {
+		t.Fatalf("Expected %q - got %q", "Other Show", subs[1].Title)
+	}
+}
+
+func TestImportPocketCasts(t *testing.T) {
+	subs := importFile(t, PocketCasts{}, "testdata/testPocketCasts.opml")
+	if len(subs) != 2 {
+		t.Fatalf("Expected %d - got %d", 2, len(subs))
+	}
+
+	for _, s := range subs {
+		if len(s.Categories) != 0 {
+			t.Fatalf("Expected %d - got %d", 0, len(s.Categories))
+		}
+	}
+}
+
+func TestImportOvercast(t *testing.T) {
+	subs := importFile(t, Overcast{}, "testdata/testOvercast.opml")
+	if len(subs) != 1 {
+		t.Fatalf("Expected %d - got %d", 1, len(subs))
+	}
+
+	episodes := []Episode{
+		{
+			URL:       "http://example.com/1.mp3",
+			Title:     "Episode 1",
+			Published: time.Date(2019, 5, 1, 16, 0, 0, 0, time.UTC),
+			Played:    true,
+		},
+		{
+			URL:       "http://example.com/2.mp3",
+			Title:     "Episode 2",
+			Published: time.Date(2019, 5, 8, 16, 0, 0, 0, time.UTC),
+		},
+	}
+
+	if len(subs[0].Episodes) != len(episodes) {
+		t.Fatalf("Expected %d - got %d", len(episodes), len(subs[0].Episodes))
+	}
+
+	for i, e := range subs[0].Episodes {
+		if e.URL != episodes[i].URL || e.Title != episodes[i].Title || e.Played != episodes[i].Played {
+			t.Fatalf("Expected %v - got %v", episodes[i], e)
+		}
+
+		if !e.Published.Equal(episodes[i].Published) {
+			t.Fatalf("Expected %v - got %v", episodes[i].Published, e.Published)
+		}
+	}
+
+	if len(subs[0].Categories) != 0 {
+		t.Fatalf("Expected %d - got %d", 0, len(subs[0].Categories))
+	}
+}
+
+func TestImportGPodder(t *testing.T) {
+	subs := importFile(t, GPodder{}, "testdata/testGPodder.json")
+	expected := []Subscription{
+		{URL: "http://example.com/feed.rss"},
+		{URL: "http://example.org/feed.rss", Title: "Other Show", Link: "http://example.org/"},
+	}
+
+	if !reflect.DeepEqual(subs, expected) {
+		t.Fatalf("Expected %v - got %v", expected, subs)
+	}
+}
+
+func TestImportURLList(t *testing.T) {
+	subs := importFile(t, URLList{}, "testdata/testURLList.txt")
+	expected := []Subscription{
+		{URL: "http://example.com/feed.rss"},
+		{URL: "http://example.org/feed.rss"},
+	}
+
+	if !reflect.DeepEqual(subs, expected) {
+		t.Fatalf("Expected %v - got %v", expected, subs)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	subs := []Subscription{
+		{
+			URL:        "http://example.com/feed.rss",
+			Title:      "Example Show",
+			Link:       "http://example.com/",
+			Categories: []string{"News/Politics", "Daily"},
+			Options:    map[string]string{"include": "foo"},
+			Episodes: []Episode{
+				{
+					URL:       "http://example.com/1.mp3",
+					Title:     "Episode 1",
+					Published: time.Date(2019, 5, 1, 16, 0, 0, 0, time.UTC),
+					Played:    true,
+				},
+			},
+		},
+		{URL: "http://example.org/feed.rss"},
+	}
+
+	for _, name := range Names() {
+		f, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err := f.Export(&buf, subs); err != nil {
+			t.Fatal(err)
+		}
+
+		imported, err := f.Import(strings.NewReader(buf.String()))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if len(imported) != len(subs) {
+			t.Fatalf("%s: Expected %d - got %d", name, len(subs), len(imported))
+		}
+
+		for i, s := range imported {
+			if s.URL != subs[i].URL {
+				t.Fatalf("%s: Expected %q - got %q", name, subs[i].URL, s.URL)
+			}
+		}
+
+		switch name {
+		case "json":
+			if !reflect.DeepEqual(imported, subs) {
+				t.Fatalf("%s: Expected %v - got %v", name, subs, imported)
+			}
+		case "opml":
+			if !reflect.DeepEqual(imported[0].Categories, subs[0].Categories) {
+				t.Fatalf("%s: Expected %q - got %q", name, subs[0].Categories, imported[0].Categories)
+			}
+		case "overcast":
+			if len(imported[0].Episodes) != 1 || !imported[0].Episodes[0].Played {
+				t.Fatalf("%s: Expected played episode - got %v", name, imported[0].Episodes)
+			}
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package exchange
+
+import (
+	"encoding/xml"
+	"github.com/nmeum/cpod/opml"
+	"io"
+	"strings"
+	"time"
+)
+
+// Layout of the episode publication dates in Overcast exports.
+const overcastLayout = "2006-01-02T15:04:05-0700"
+
+// OPML is the generic OPML format. Folders are mapped to categories and
+// vice versa, all categories are exported as category attribute too.
+type OPML struct{}
+
+// AntennaPod is the OPML format used by AntennaPod. It doesn't support
+// folders, thus all feeds are exported as top-level outlines.
+type AntennaPod struct{ OPML }
+
+// PocketCasts is the OPML format used by Pocket Casts. All feeds are
+// contained in a single folder named feeds.
+type PocketCasts struct{ OPML }
+
+// Overcast is the OPML format used by Overcast. All feeds are contained
+// in a folder named feeds, the outlines of feeds may contain outlines
+// of played episodes.
+type Overcast struct{}
+
+// Import returns all outlines referencing a feed, regardless of their
+// nesting.
+func (OPML) Import(r io.Reader) ([]Subscription, error) {
+	doc, err := opml.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var subs []Subscription
+	doc.Walk(func(parents []string, o *opml.Outline) bool {
+		if s, ok := subscription(*o); ok {
+			if len(parents) > 0 {
+				s.Categories = addCategory(s.Categories, strings.Join(parents, "/"))
+			}
+
+			subs = append(subs, s)
+		}
+
+		return true
+	})
+
+	return subs, nil
+}
+
+// Export writes an OPML document with a folder for the first category
+// of every subscription.
+func (OPML) Export(w io.Writer, subs []Subscription) error {
+	doc := opml.Create("Podcast subscriptions")
+	for _, s := range subs {
+		o := outline(s)
+		if len(s.Categories) > 0 {
+			o.Category = "/" + strings.Join(s.Categories, ",/")
+		}
+
+		parent := &doc.Outlines
+		if len(s.Categories) > 0 {
+			parent = folder(parent, strings.Split(s.Categories[0], "/"))
+		}
+
+		*parent = append(*parent, o)
+	}
+
+	return doc.Write(w)
+}
+
+// Export writes an OPML document without folders.
+func (AntennaPod) Export(w io.Writer, subs []Subscription) error {
+	doc := opml.Create("AntennaPod Subscriptions")
+	for _, s := range subs {
+		doc.Outlines = append(doc.Outlines, outline(s))
+	}
+
+	return doc.Write(w)
+}
+
+// Import returns all outlines referencing a feed, the feeds folder is
+// not considered a category.
+func (f PocketCasts) Import(r io.Reader) ([]Subscription, error) {
+	subs, err := f.OPML.Import(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return stripFolder(subs, "feeds"), nil
+}
+
+// Export writes an OPML document containing a single feeds folder.
+func (PocketCasts) Export(w io.Writer, subs []Subscription) error {
+	doc := opml.Create("Pocket Casts Feeds")
+	doc.Version = "1.0"
+
+	feeds := opml.Outline{Text: "feeds"}
+	for _, s := range subs {
+		feeds.Outlines = append(feeds.Outlines, opml.Outline{
+			Text: s.text(),
+			Type: "rss",
+			URL:  s.URL,
+		})
+	}
+
+	doc.Outlines = []opml.Outline{feeds}
+	return doc.Write(w)
+}
+
+// Import returns all subscribed feeds including the episodes the user
+// interacted with.
+func (Overcast) Import(r io.Reader) ([]Subscription, error) {
+	subs, err := OPML{}.Import(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return stripFolder(subs, "feeds"), nil
+}
+
+// Export writes an OPML document containing a single feeds folder with
+// the episodes of each subscription nested in its outline.
+func (Overcast) Export(w io.Writer, subs []Subscription) error {
+	doc := opml.Create("Overcast Podcast Subscriptions")
+	doc.Version = "1.0"
+
+	feeds := opml.Outline{Text: "feeds"}
+	for _, s := range subs {
+		o := outline(s)
+		o.Attrs = []xml.Attr{attr("subscribed", "1")}
+
+		for _, e := range s.Episodes {
+			episode := opml.Outline{Type: "podcast-episode", Title: e.Title}
+			if !e.Published.IsZero() {
+				episode.Attrs = append(episode.Attrs, attr("pubDate", e.Published.Format(overcastLayout)))
+			}
+
+			played := "0"
+			if e.Played {
+				played = "1"
+			}
+
+			episode.Attrs = append(episode.Attrs, attr("enclosureUrl", e.URL), attr("played", played))
+			o.Outlines = append(o.Outlines, episode)
+		}
+
+		feeds.Outlines = append(feeds.Outlines, o)
+	}
+
+	doc.Outlines = []opml.Outline{feeds}
+	return doc.Write(w)
+}
+
+// subscription converts the given outline to a subscription. If the
+// outline doesn't reference a feed, false is returned.
+func subscription(o opml.Outline) (Subscription, bool) {
+	url := strings.TrimSpace(o.URL)
+	if len(url) <= 0 {
+		// Some clients use a lowercase attribute name.
+		url = strings.TrimSpace(lookupAttr(o, "xmlurl"))
+	}
+
+	if len(url) <= 0 || lookupAttr(o, "subscribed") == "0" {
+		return Subscription{}, false
+	}
+
+	s := Subscription{
+		URL:        url,
+		Title:      o.Title,
+		Link:       o.HTMLURL,
+		Categories: o.Categories(),
+	}
+
+	if len(s.Title) <= 0 {
+		s.Title = o.Text
+	}
+
+	for _, child := range o.Outlines {
+		if child.Type != "podcast-episode" {
+			continue
+		}
+
+		e := Episode{Title: child.Title, Played: lookupAttr(child, "played") == "1"}
+		if e.URL = lookupAttr(child, "enclosureUrl"); len(e.URL) <= 0 {
+			e.URL = lookupAttr(child, "url")
+		}
+
+		date := lookupAttr(child, "pubDate")
+		if t, err := time.Parse(overcastLayout, date); err == nil {
+			e.Published = t
+		} else if t, err := time.Parse(time.RFC3339, date); err == nil {
+			e.Published = t
+		}
+
+		s.Episodes = append(s.Episodes, e)
+	}
+
+	return s, true
+}
+
+// outline converts the given subscription to an outline.
+func outline(s Subscription) opml.Outline {
+	return opml.Outline{
+		Text:    s.text(),
+		Title:   s.Title,
+		Type:    "rss",
+		URL:     s.URL,
+		HTMLURL: s.Link,
+	}
+}
+
+// folder returns the outlines of the folder with the given path below
+// the given outlines. Missing folders are created.
+func folder(outlines *[]opml.Outline, path []string) *[]opml.Outline {
+	for _, name := range path {
+		i := -1
+		for j, o := range *outlines {
+			if !o.IsFeed() && o.Text == name {
+				i = j
+				break
+			}
+		}
+
+		if i < 0 {
+			*outlines = append(*outlines, opml.Outline{Text: name})
+			i = len(*outlines) - 1
+		}
+
+		outlines = &(*outlines)[i].Outlines
+	}
+
+	return outlines
+}
+
+// stripFolder removes the top-level folder with the given name from the
+// categories of the given subscriptions.
+func stripFolder(subs []Subscription, name string) []Subscription {
+	for i := range subs {
+		var categories []string
+		for _, c := range subs[i].Categories {
+			if c == name {
+				continue
+			} else if strings.HasPrefix(c, name+"/") {
+				c = c[len(name)+1:]
+			}
+
+			categories = append(categories, c)
+		}
+
+		subs[i].Categories = categories
+	}
+
+	return subs
+}
+
+// addCategory appends the given category unless it is already part of
+// the given categories.
+func addCategory(categories []string, category string) []string {
+	for _, c := range categories {
+		if c == category {
+			return categories
+		}
+	}
+
+	return append(categories, category)
+}
+
+// lookupAttr returns the value of the attribute of the given outline
+// whose name is equal to the given name ignoring case and namespace.
+func lookupAttr(o opml.Outline, name string) string {
+	for _, a := range o.Attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+func attr(name, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
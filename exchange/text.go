@@ -0,0 +1,129 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package exchange
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// URLList is a plain text format containing one feed URL per line.
+// Empty lines and lines starting with # are ignored, text following
+// the URL on the same line, e.g. cpod feed options, is ignored as well.
+type URLList struct{}
+
+// JSON is the JSON format of cpod, it retains all information of the
+// subscriptions including their feed options.
+type JSON struct{}
+
+// GPodder is the JSON format of the gpodder.net subscription API. It
+// is a list of feed URLs, lists of podcast objects are imported too.
+type GPodder struct{}
+
+// gpodderPodcast represents a podcast object of the gpodder.net API.
+type gpodderPodcast struct {
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Website string `json:"website"`
+}
+
+// Import returns the subscriptions of all lines containing a URL.
+func (URLList) Import(r io.Reader) ([]Subscription, error) {
+	var subs []Subscription
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) <= 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		subs = append(subs, Subscription{URL: strings.Fields(line)[0]})
+	}
+
+	return subs, scanner.Err()
+}
+
+// Export writes the URLs of the given subscriptions.
+func (URLList) Export(w io.Writer, subs []Subscription) error {
+	for _, s := range subs {
+		if _, err := fmt.Fprintln(w, s.URL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import decodes a list of subscriptions.
+func (JSON) Import(r io.Reader) (subs []Subscription, err error) {
+	err = json.NewDecoder(r).Decode(&subs)
+	return
+}
+
+// Export encodes the given subscriptions as indented list.
+func (JSON) Export(w io.Writer, subs []Subscription) error {
+	if subs == nil {
+		subs = []Subscription{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+
+	return encoder.Encode(subs)
+}
+
+// Import decodes a list of URLs or podcast objects.
+func (GPodder) Import(r io.Reader) ([]Subscription, error) {
+	var list []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	var subs []Subscription
+	for _, elem := range list {
+		var url string
+		if err := json.Unmarshal(elem, &url); err == nil {
+			subs = append(subs, Subscription{URL: url})
+			continue
+		}
+
+		var p gpodderPodcast
+		if err := json.Unmarshal(elem, &p); err != nil {
+			return nil, err
+		} else if len(p.URL) <= 0 {
+			return nil, errors.New("podcast without URL")
+		}
+
+		subs = append(subs, Subscription{URL: p.URL, Title: p.Title, Link: p.Website})
+	}
+
+	return subs, nil
+}
+
+// Export encodes the URLs of the given subscriptions as list.
+func (GPodder) Export(w io.Writer, subs []Subscription) error {
+	urls := []string{}
+	for _, s := range subs {
+		urls = append(urls, s.URL)
+	}
+
+	return json.NewEncoder(w).Encode(urls)
+}
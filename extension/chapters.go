@@ -0,0 +1,79 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package extension
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Chapter represents a chapter of an episode.
+type Chapter struct {
+	// Start of the chapter relative to the beginning of the episode.
+	Start time.Duration
+
+	// Title of the chapter.
+	Title string
+}
+
+type chapters struct {
+	Chapters []struct {
+		StartTime float64 `json:"startTime"`
+		Title     string  `json:"title"`
+		TOC       *bool   `json:"toc"`
+	} `json:"chapters"`
+}
+
+// ParseChapters reads a podcast namespace JSON chapters file from the
+// given reader. Chapters which should not be displayed in the table of
+// contents are omitted.
+func ParseChapters(r io.Reader) (out []Chapter, err error) {
+	var c chapters
+	if err = json.NewDecoder(r).Decode(&c); err != nil {
+		return
+	}
+
+	for _, chapter := range c.Chapters {
+		if chapter.TOC != nil && !*chapter.TOC {
+			continue
+		}
+
+		out = append(out, Chapter{
+			Start: time.Duration(chapter.StartTime * float64(time.Second)),
+			Title: chapter.Title,
+		})
+	}
+
+	return
+}
+
+// WriteChapters writes the given chapters in the simple chapter format
+// (one HH:MM:SS.mmm timestamp followed by the title per line) to the
+// given writer.
+func WriteChapters(w io.Writer, chapters []Chapter) error {
+	for _, c := range chapters {
+		ms := c.Start / time.Millisecond
+		_, err := fmt.Fprintf(w, "%02d:%02d:%02d.%03d %s\n", ms/3600000,
+			ms/60000%60, ms/1000%60, ms%1000, c.Title)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package extension
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseChapters(t *testing.T) {
+	file, err := os.Open("testdata/testChapters.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	chapters, err := ParseChapters(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chapters) != 2 {
+		t.Fatalf("Expected %d - got %d", 2, len(chapters))
+	}
+
+	expected := Chapter{61*time.Minute + 1500*time.Millisecond, "Main topic"}
+	if chapters[1] != expected {
+		t.Fatalf("Expected %v - got %v", expected, chapters[1])
+	}
+}
+
+func TestWriteChapters(t *testing.T) {
+	chapters := []Chapter{
+		{0, "Intro"},
+		{61*time.Minute + 1500*time.Millisecond, "Main topic"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteChapters(&buf, chapters); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "00:00:00.000 Intro\n01:01:01.500 Main topic\n"
+	if buf.String() != expected {
+		t.Fatalf("Expected %q - got %q", expected, buf.String())
+	}
+}
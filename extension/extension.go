@@ -0,0 +1,410 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package extension implements a parser for podcast related feed
+// elements which are not exposed by go-feedparser, for instance the
+// enclosure type or the iTunes duration of an item.
+package extension
+
+import (
+	"encoding/xml"
+	"errors"
+	"golang.org/x/net/html/charset"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feed represents the extension data of a feed.
+type Feed struct {
+	// Atom links of the feed, e.g. links to other pages of the feed.
+	Links []Link
+
+	// Time the feed may be cached before refreshing it, zero if
+	// unspecified. Determined from the RSS ttl element or the
+	// syndication module update period.
+	TTL time.Duration
+
+	// New URL of the feed announced using itunes:new-feed-url, empty
+	// if the feed didn't move.
+	NewFeedURL string
+
+	// Globally unique identifier of the podcast (podcast:guid), empty
+	// if unspecified.
+	GUID string
+
+	// Items of the feed in document order.
+	Items []Item
+}
+
+// Link represents an Atom link.
+type Link struct {
+	// Relation type of the link.
+	Rel string
+
+	// URL the link refers to, might be relative.
+	Href string
+}
+
+// Item represents the extension data of a feed item.
+type Item struct {
+	// Globally unique identifier of the item.
+	GUID string
+
+	// Description or summary of the item.
+	Description string
+
+	// Duration of the episode, zero if unknown.
+	Duration time.Duration
+
+	// Enclosures attached to the item.
+	Enclosures []Enclosure
+
+	// Transcripts of the episode.
+	Transcripts []Transcript
+
+	// URL of the JSON chapters file.
+	Chapters string
+}
+
+// Transcript represents a transcript of an item.
+type Transcript struct {
+	// URL of the transcript.
+	URL string
+
+	// MIME type of the transcript.
+	Type string
+
+	// Language of the transcript.
+	Language string
+}
+
+// Enclosure represents a file attached to an item.
+type Enclosure struct {
+	// URL of the file.
+	URL string
+
+	// MIME type of the file.
+	Type string
+
+	// Length of the file in bytes, zero if unknown.
+	Length int64
+
+	// Bitrate of the file in bits per second, zero if unknown.
+	Bitrate int64
+
+	// Codecs used by the file as specified in RFC 6381.
+	Codecs string
+
+	// Whether the file is an alternate enclosure.
+	Alternate bool
+}
+
+type link struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type alternateEnclosure struct {
+	Type    string `xml:"type,attr"`
+	Length  string `xml:"length,attr"`
+	Bitrate string `xml:"bitrate,attr"`
+	Codecs  string `xml:"codecs,attr"`
+	Sources []struct {
+		URI string `xml:"uri,attr"`
+	} `xml:"https://podcastindex.org/namespace/1.0 source"`
+}
+
+type transcript struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Language string `xml:"language,attr"`
+}
+
+type rssItem struct {
+	GUID        string               `xml:"guid"`
+	Description string               `xml:"description"`
+	Summary     string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	Duration    string               `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Enclosures  []enclosure          `xml:"enclosure"`
+	Alternates  []alternateEnclosure `xml:"https://podcastindex.org/namespace/1.0 alternateEnclosure"`
+	Transcripts []transcript         `xml:"https://podcastindex.org/namespace/1.0 transcript"`
+	Chapters    struct {
+		URL string `xml:"url,attr"`
+	} `xml:"https://podcastindex.org/namespace/1.0 chapters"`
+}
+
+type atomEntry struct {
+	ID       string `xml:"http://www.w3.org/2005/Atom id"`
+	Summary  string `xml:"http://www.w3.org/2005/Atom summary"`
+	Content  string `xml:"http://www.w3.org/2005/Atom content"`
+	Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Links    []link `xml:"http://www.w3.org/2005/Atom link"`
+}
+
+type document struct {
+	XMLName         xml.Name
+	TTL             string      `xml:"channel>ttl"`
+	UpdatePeriod    string      `xml:"http://purl.org/rss/1.0/modules/syndication/ channel>updatePeriod"`
+	UpdateFrequency string      `xml:"http://purl.org/rss/1.0/modules/syndication/ channel>updateFrequency"`
+	ChannelLinks    []link      `xml:"http://www.w3.org/2005/Atom channel>link"`
+	NewFeedURL      string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd channel>new-feed-url"`
+	GUID            string      `xml:"https://podcastindex.org/namespace/1.0 channel>guid"`
+	Items           []rssItem   `xml:"channel>item"`
+	FeedLinks       []link      `xml:"http://www.w3.org/2005/Atom link"`
+	Entries         []atomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+// Parse reads the extension data of the RSS or Atom feed from the
+// given reader.
+func Parse(r io.Reader) (f Feed, err error) {
+	var doc document
+
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	if err = decoder.Decode(&doc); err != nil {
+		return
+	}
+
+	switch doc.XMLName.Local {
+	case "rss":
+		f.TTL = doc.ttl()
+		f.NewFeedURL = strings.TrimSpace(doc.NewFeedURL)
+		f.GUID = strings.TrimSpace(doc.GUID)
+		f.Links = convertLinks(doc.ChannelLinks)
+		for _, i := range doc.Items {
+			f.Items = append(f.Items, i.convert())
+		}
+	case "feed":
+		f.Links = convertLinks(doc.FeedLinks)
+		for _, e := range doc.Entries {
+			f.Items = append(f.Items, e.convert())
+		}
+	default:
+		err = errors.New("unsupported feed type")
+	}
+
+	return
+}
+
+// Link returns the reference of the first link with the given
+// relation type or an empty string if the feed has no such link.
+func (f Feed) Link(rel string) string {
+	for _, l := range f.Links {
+		if l.Rel == rel {
+			return l.Href
+		}
+	}
+
+	return ""
+}
+
+// Lookup returns the item with an enclosure matching the given
+// attachment URL. The boolean return value is false if no such item
+// exists.
+func (f Feed) Lookup(attachment string) (Item, bool) {
+	attachment = strings.TrimSpace(attachment)
+	for _, item := range f.Items {
+		if _, ok := item.Enclosure(attachment); ok {
+			return item, true
+		}
+	}
+
+	return Item{}, false
+}
+
+// Enclosure returns the enclosure of the item with the given URL. The
+// boolean return value is false if the item has no such enclosure.
+func (i Item) Enclosure(url string) (Enclosure, bool) {
+	for _, e := range i.Enclosures {
+		if e.URL == url {
+			return e, true
+		}
+	}
+
+	return Enclosure{}, false
+}
+
+func (i rssItem) convert() Item {
+	item := Item{
+		GUID:        strings.TrimSpace(i.GUID),
+		Description: i.Description,
+		Duration:    ParseDuration(i.Duration),
+		Chapters:    strings.TrimSpace(i.Chapters.URL),
+	}
+
+	if len(item.Description) <= 0 {
+		item.Description = i.Summary
+	}
+
+	for _, t := range i.Transcripts {
+		item.Transcripts = append(item.Transcripts, Transcript{
+			URL:      strings.TrimSpace(t.URL),
+			Type:     strings.TrimSpace(t.Type),
+			Language: strings.TrimSpace(t.Language),
+		})
+	}
+
+	for _, e := range i.Enclosures {
+		item.Enclosures = append(item.Enclosures, Enclosure{
+			URL:    strings.TrimSpace(e.URL),
+			Type:   strings.TrimSpace(e.Type),
+			Length: parseLength(e.Length),
+		})
+	}
+
+	for _, a := range i.Alternates {
+		url, ok := a.source()
+		if !ok {
+			continue
+		}
+
+		bitrate, _ := strconv.ParseFloat(strings.TrimSpace(a.Bitrate), 64)
+		item.Enclosures = append(item.Enclosures, Enclosure{
+			URL:       url,
+			Type:      strings.TrimSpace(a.Type),
+			Length:    parseLength(a.Length),
+			Bitrate:   int64(bitrate),
+			Codecs:    strings.TrimSpace(a.Codecs),
+			Alternate: true,
+		})
+	}
+
+	return item
+}
+
+// source returns the first HTTP source of an alternate enclosure,
+// other sources like torrents are not supported.
+func (a alternateEnclosure) source() (string, bool) {
+	for _, s := range a.Sources {
+		uri := strings.TrimSpace(s.URI)
+		if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+			return uri, true
+		}
+	}
+
+	return "", false
+}
+
+func (e atomEntry) convert() Item {
+	item := Item{
+		GUID:        strings.TrimSpace(e.ID),
+		Description: e.Summary,
+		Duration:    ParseDuration(e.Duration),
+	}
+
+	if len(item.Description) <= 0 {
+		item.Description = e.Content
+	}
+
+	for _, l := range e.Links {
+		if l.Rel != "enclosure" {
+			continue
+		}
+
+		item.Enclosures = append(item.Enclosures, Enclosure{
+			URL:    strings.TrimSpace(l.Href),
+			Type:   strings.TrimSpace(l.Type),
+			Length: parseLength(l.Length),
+		})
+	}
+
+	return item
+}
+
+// ttl returns the time the feed may be cached. The ttl element takes
+// precedence over the syndication module.
+func (d document) ttl() time.Duration {
+	minutes, err := strconv.Atoi(strings.TrimSpace(d.TTL))
+	if err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+
+	var period time.Duration
+	switch strings.TrimSpace(d.UpdatePeriod) {
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	case "weekly":
+		period = 7 * 24 * time.Hour
+	case "monthly":
+		period = 30 * 24 * time.Hour
+	case "yearly":
+		period = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+
+	frequency, err := strconv.Atoi(strings.TrimSpace(d.UpdateFrequency))
+	if err != nil || frequency <= 0 {
+		frequency = 1
+	}
+
+	return period / time.Duration(frequency)
+}
+
+func convertLinks(links []link) (out []Link) {
+	for _, l := range links {
+		rel := strings.TrimSpace(l.Rel)
+		if len(rel) <= 0 {
+			rel = "alternate"
+		}
+
+		out = append(out, Link{rel, strings.TrimSpace(l.Href)})
+	}
+
+	return
+}
+
+// ParseDuration parses an iTunes duration. The duration is either
+// given in seconds or in the form HH:MM:SS or MM:SS. Zero is returned
+// if the duration couldn't be parsed.
+func ParseDuration(s string) time.Duration {
+	fields := strings.Split(strings.TrimSpace(s), ":")
+	if len(fields) > 3 {
+		return 0
+	}
+
+	var secs float64
+	for _, field := range fields {
+		n, err := strconv.ParseFloat(field, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+
+		secs = secs*60 + n
+	}
+
+	return time.Duration(secs * float64(time.Second))
+}
+
+func parseLength(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+
+	return n
+}
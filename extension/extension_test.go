@@ -0,0 +1,176 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package extension
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func parseFile(t *testing.T, path string) Feed {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	f, err := Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f
+}
+
+func TestParseRSS(t *testing.T) {
+	f := parseFile(t, "testdata/testParse.rss")
+	if len(f.Items) != 2 {
+		t.Fatalf("Expected %d - got %d", 2, len(f.Items))
+	}
+
+	if f.TTL != 90*time.Minute {
+		t.Fatalf("Expected %v - got %v", 90*time.Minute, f.TTL)
+	}
+
+	if f.NewFeedURL != "" {
+		t.Fatalf("Expected %q - got %q", "", f.NewFeedURL)
+	}
+
+	if f.GUID != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("Expected %q - got %q", "917393e3-1b1e-5cef-ace4-edaa54e1f810", f.GUID)
+	}
+
+	if f.Link("prev-archive") != "feed.rss?page=2" {
+		t.Fatalf("Expected %q - got %q", "feed.rss?page=2", f.Link("prev-archive"))
+	}
+
+	if f.Link("next") != "" {
+		t.Fatalf("Expected %q - got %q", "", f.Link("next"))
+	}
+
+	item := f.Items[0]
+	if item.GUID != "http://example.com/episodes/2" {
+		t.Fatalf("Expected %q - got %q", "http://example.com/episodes/2", item.GUID)
+	}
+
+	if item.Description != "Second episode" {
+		t.Fatalf("Expected %q - got %q", "Second episode", item.Description)
+	}
+
+	expected := time.Hour + 2*time.Minute + 3*time.Second
+	if item.Duration != expected {
+		t.Fatalf("Expected %v - got %v", expected, item.Duration)
+	}
+
+	enclosure := Enclosure{URL: "http://example.com/2.mp3", Type: "audio/mpeg", Length: 4242}
+	if len(item.Enclosures) != 2 || item.Enclosures[0] != enclosure {
+		t.Fatalf("Expected %v - got %v", enclosure, item.Enclosures)
+	}
+
+	alternate := Enclosure{"http://example.com/2.opus", "audio/opus", 1337, 32000, "opus", true}
+	if item.Enclosures[1] != alternate {
+		t.Fatalf("Expected %v - got %v", alternate, item.Enclosures[1])
+	}
+
+	transcript := Transcript{"http://example.com/2.vtt", "text/vtt", "en"}
+	if len(item.Transcripts) != 1 || item.Transcripts[0] != transcript {
+		t.Fatalf("Expected %v - got %v", transcript, item.Transcripts)
+	}
+
+	if item.Chapters != "http://example.com/2.json" {
+		t.Fatalf("Expected %q - got %q", "http://example.com/2.json", item.Chapters)
+	}
+
+	if f.Items[1].Description != "Trailer for the show" {
+		t.Fatalf("Expected %q - got %q", "Trailer for the show", f.Items[1].Description)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	f := parseFile(t, "testdata/testParse.atom")
+	if len(f.Items) != 1 {
+		t.Fatalf("Expected %d - got %d", 1, len(f.Items))
+	}
+
+	if f.Link("alternate") != "http://example.org" {
+		t.Fatalf("Expected %q - got %q", "http://example.org", f.Link("alternate"))
+	}
+
+	if f.Link("next") != "http://example.org/feed.atom?page=2" {
+		t.Fatalf("Expected %q - got %q", "http://example.org/feed.atom?page=2", f.Link("next"))
+	}
+
+	item := f.Items[0]
+	if item.Duration != 12*time.Minute+30*time.Second {
+		t.Fatalf("Expected %v - got %v", 12*time.Minute+30*time.Second, item.Duration)
+	}
+
+	enclosure := Enclosure{URL: "http://example.org/1.opus", Type: "audio/ogg", Length: 1024}
+	if len(item.Enclosures) != 1 || item.Enclosures[0] != enclosure {
+		t.Fatalf("Expected %v - got %v", enclosure, item.Enclosures)
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	f := parseFile(t, "testdata/testTTL.rss")
+	if f.TTL != 6*time.Hour {
+		t.Fatalf("Expected %v - got %v", 6*time.Hour, f.TTL)
+	}
+
+	if f.NewFeedURL != "https://example.org/feed.rss" {
+		t.Fatalf("Expected %q - got %q", "https://example.org/feed.rss", f.NewFeedURL)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f := parseFile(t, "testdata/testParse.rss")
+
+	item, ok := f.Lookup("http://example.com/1.mp4")
+	if !ok {
+		t.Fatal("Couldn't find item")
+	}
+
+	if item.GUID != "http://example.com/episodes/1" {
+		t.Fatalf("Expected %q - got %q", "http://example.com/episodes/1", item.GUID)
+	}
+
+	if _, ok := f.Lookup("http://example.com/3.mp3"); ok {
+		t.Fail()
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	type testpair struct {
+		input    string
+		expected time.Duration
+	}
+
+	tests := []testpair{
+		{"3600", time.Hour},
+		{"05:30", 5*time.Minute + 30*time.Second},
+		{"1:00:00", time.Hour},
+		{"foo", 0},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		d := ParseDuration(test.input)
+		if d != test.expected {
+			t.Fatalf("Expected %v - got %v", test.expected, d)
+		}
+	}
+}
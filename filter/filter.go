@@ -0,0 +1,165 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package filter implements per-feed rules deciding which episodes of
+// a feed should be downloaded.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Layout of dates used by the after option.
+const dateLayout = "2006-01-02"
+
+// Episode represents the properties of an episode a filter is
+// evaluated on.
+type Episode struct {
+	// Title of the episode.
+	Title string
+
+	// Description of the episode.
+	Description string
+
+	// MIME type of the episode file.
+	Type string
+
+	// Duration of the episode, zero if unknown.
+	Duration time.Duration
+
+	// Publication date of the episode.
+	Published time.Time
+}
+
+// Filter represents a set of rules an episode needs to match.
+type Filter struct {
+	// Regex the title or description must match.
+	Include *regexp.Regexp
+
+	// Regex neither the title nor the description must match.
+	Exclude *regexp.Regexp
+
+	// MIME type patterns of which one must match.
+	Types []string
+
+	// MIME type patterns of which none must match.
+	NoTypes []string
+
+	// Minimum duration of the episode.
+	MinDuration time.Duration
+
+	// Maximum duration of the episode.
+	MaxDuration time.Duration
+
+	// Date the episode must be published after.
+	After time.Time
+}
+
+// Parse creates a new filter from the given feed options. Options
+// which are not related to filtering are ignored.
+func Parse(opts map[string]string) (f *Filter, err error) {
+	f = new(Filter)
+	for key, value := range opts {
+		switch key {
+		case "include":
+			f.Include, err = regexp.Compile(value)
+		case "exclude":
+			f.Exclude, err = regexp.Compile(value)
+		case "type":
+			f.Types = strings.Split(value, ",")
+		case "notype":
+			f.NoTypes = strings.Split(value, ",")
+		case "min-duration":
+			f.MinDuration, err = time.ParseDuration(value)
+		case "max-duration":
+			f.MaxDuration, err = time.ParseDuration(value)
+		case "after":
+			f.After, err = time.Parse(dateLayout, value)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %s", key, err)
+		}
+	}
+
+	return
+}
+
+// Match returns true if the given episode matches all rules of the
+// filter. Rules which can't be evaluated because the episode lacks
+// the required information, e.g. an unknown duration, are ignored.
+func (f *Filter) Match(e Episode) bool {
+	if f.Include != nil && !matchText(f.Include, e) {
+		return false
+	}
+
+	if f.Exclude != nil && matchText(f.Exclude, e) {
+		return false
+	}
+
+	if len(e.Type) > 0 {
+		if len(f.Types) > 0 && !matchType(f.Types, e.Type) {
+			return false
+		}
+
+		if matchType(f.NoTypes, e.Type) {
+			return false
+		}
+	}
+
+	if e.Duration > 0 {
+		if f.MinDuration > 0 && e.Duration < f.MinDuration {
+			return false
+		}
+
+		if f.MaxDuration > 0 && e.Duration > f.MaxDuration {
+			return false
+		}
+	}
+
+	if !f.After.IsZero() && !e.Published.IsZero() && !e.Published.After(f.After) {
+		return false
+	}
+
+	return true
+}
+
+// matchText returns true if the regex matches either the title or the
+// description of the given episode.
+func matchText(re *regexp.Regexp, e Episode) bool {
+	return re.MatchString(e.Title) || re.MatchString(e.Description)
+}
+
+// matchType returns true if one of the given patterns matches the
+// MIME type. Parameters of the MIME type are ignored.
+func matchType(patterns []string, mimetype string) bool {
+	if i := strings.Index(mimetype, ";"); i >= 0 {
+		mimetype = mimetype[0:i]
+	}
+	mimetype = strings.ToLower(strings.TrimSpace(mimetype))
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if ok, _ := path.Match(pattern, mimetype); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	opts := map[string]string{
+		"exclude":      "(?i)trailer",
+		"type":         "audio/*",
+		"min-duration": "10m",
+		"after":        "2015-01-01",
+		"foo":          "bar",
+	}
+
+	f, err := Parse(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Exclude == nil || f.Include != nil {
+		t.Fail()
+	}
+
+	if len(f.Types) != 1 || f.Types[0] != "audio/*" {
+		t.Fatalf("Expected %q - got %q", []string{"audio/*"}, f.Types)
+	}
+
+	if f.MinDuration != 10*time.Minute {
+		t.Fatalf("Expected %v - got %v", 10*time.Minute, f.MinDuration)
+	}
+
+	if _, err := Parse(map[string]string{"max-duration": "1x"}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestMatch(t *testing.T) {
+	type testpair struct {
+		episode  Episode
+		expected bool
+	}
+
+	f, err := Parse(map[string]string{
+		"exclude":      "(?i)trailer",
+		"notype":       "video/*",
+		"min-duration": "5m",
+		"after":        "2015-01-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []testpair{
+		{Episode{"Episode 1", "", "audio/mpeg", time.Hour, date}, true},
+		{Episode{"Trailer", "", "audio/mpeg", time.Hour, date}, false},
+		{Episode{"Episode 2", "Our new TRAILER", "audio/mpeg", time.Hour, date}, false},
+		{Episode{"Episode 3", "", "video/mp4; codecs=avc1", time.Hour, date}, false},
+		{Episode{"Episode 4", "", "audio/mpeg", time.Minute, date}, false},
+		{Episode{"Episode 5", "", "", 0, date}, true},
+		{Episode{"Episode 6", "", "audio/mpeg", time.Hour, date.AddDate(-1, 0, 0)}, false},
+		{Episode{"Episode 7", "", "audio/mpeg", time.Hour, time.Time{}}, true},
+	}
+
+	for _, test := range tests {
+		if f.Match(test.episode) != test.expected {
+			t.Fatalf("Expected %v for %q", test.expected, test.episode.Title)
+		}
+	}
+}
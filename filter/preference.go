@@ -0,0 +1,144 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package filter
+
+import (
+	"fmt"
+	"github.com/nmeum/cpod/extension"
+	"strconv"
+	"strings"
+)
+
+// Preference represents per-feed preferences used to choose between
+// multiple enclosures of an item.
+type Preference struct {
+	// MIME type patterns ordered by preference.
+	Types []string
+
+	// Preferred codec of alternate enclosures.
+	Codec string
+
+	// Maximum bitrate in bits per second.
+	MaxBitrate int64
+
+	// Maximum file size in bytes.
+	MaxSize int64
+}
+
+// ParsePreference creates a new preference from the given feed
+// options. Options which are not related to enclosures are ignored.
+func ParsePreference(opts map[string]string) (p *Preference, err error) {
+	p = new(Preference)
+	for key, value := range opts {
+		switch key {
+		case "prefer-type":
+			p.Types = strings.Split(value, ",")
+		case "prefer-codec":
+			p.Codec = strings.ToLower(strings.TrimSpace(value))
+		case "max-bitrate":
+			p.MaxBitrate, err = parseSize(value)
+		case "max-size":
+			p.MaxSize, err = parseSize(value)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %s", key, err)
+		}
+	}
+
+	return
+}
+
+// Choose returns the enclosure which matches the preference best.
+// Enclosures exceeding the maximum bitrate or size are never chosen,
+// unless their bitrate or size is unknown. If multiple enclosures
+// match equally well the first one is returned. The boolean return
+// value is false if no enclosure is acceptable.
+func (p *Preference) Choose(enclosures []extension.Enclosure) (best extension.Enclosure, ok bool) {
+	var bestScore int
+	for _, e := range enclosures {
+		if p.MaxBitrate > 0 && e.Bitrate > p.MaxBitrate {
+			continue
+		} else if p.MaxSize > 0 && e.Length > p.MaxSize {
+			continue
+		}
+
+		score := p.score(e)
+		if !ok || score > bestScore {
+			best, bestScore, ok = e, score, true
+		}
+	}
+
+	return
+}
+
+// score rates how well an enclosure matches the preference, higher
+// scores are better. A matching codec outweighs all type preferences.
+func (p *Preference) score(e extension.Enclosure) (score int) {
+	if len(p.Codec) > 0 && e.Alternate && matchCodec(p.Codec, e.Codecs) {
+		score += len(p.Types) + 1
+	}
+
+	for i, pattern := range p.Types {
+		if matchType([]string{pattern}, e.Type) {
+			score += len(p.Types) - i
+			break
+		}
+	}
+
+	return
+}
+
+// matchCodec returns true if the given comma separated RFC 6381 codecs
+// list contains the codec.
+func matchCodec(codec, codecs string) bool {
+	for _, c := range strings.Split(strings.ToLower(codecs), ",") {
+		c = strings.TrimSpace(c)
+		if c == codec || strings.HasPrefix(c, codec+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseSize parses a number with an optional k, M or G suffix.
+func parseSize(s string) (int64, error) {
+	var mult int64 = 1
+	s = strings.TrimSpace(s)
+
+	if len(s) > 0 {
+		switch s[len(s)-1] {
+		case 'k', 'K':
+			mult = 1000
+		case 'M':
+			mult = 1000 * 1000
+		case 'G':
+			mult = 1000 * 1000 * 1000
+		}
+
+		if mult > 1 {
+			s = s[0 : len(s)-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return n * mult, nil
+}
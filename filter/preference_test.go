@@ -0,0 +1,93 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package filter
+
+import (
+	"github.com/nmeum/cpod/extension"
+	"testing"
+)
+
+var enclosures = []extension.Enclosure{
+	{URL: "http://a.com/hq.mp3", Type: "audio/mpeg", Length: 90000000, Bitrate: 320000},
+	{URL: "http://a.com/lq.mp3", Type: "audio/mpeg", Length: 20000000, Bitrate: 64000, Alternate: true},
+	{URL: "http://a.com/ep.opus", Type: "audio/ogg", Length: 10000000, Bitrate: 32000, Codecs: "opus", Alternate: true},
+	{URL: "http://a.com/ep.mp4", Type: "video/mp4", Length: 500000000, Alternate: true},
+}
+
+func TestParsePreference(t *testing.T) {
+	p, err := ParsePreference(map[string]string{
+		"prefer-type":  "audio/ogg,audio/*",
+		"prefer-codec": "Opus",
+		"max-bitrate":  "64k",
+		"max-size":     "50M",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Types) != 2 || p.Codec != "opus" {
+		t.Fail()
+	}
+
+	if p.MaxBitrate != 64000 {
+		t.Fatalf("Expected %d - got %d", 64000, p.MaxBitrate)
+	}
+
+	if p.MaxSize != 50000000 {
+		t.Fatalf("Expected %d - got %d", 50000000, p.MaxSize)
+	}
+
+	if _, err := ParsePreference(map[string]string{"max-size": "1T"}); err == nil {
+		t.Fail()
+	}
+}
+
+func TestChoose(t *testing.T) {
+	type testpair struct {
+		opts     map[string]string
+		expected string
+	}
+
+	tests := []testpair{
+		{map[string]string{}, "http://a.com/hq.mp3"},
+		{map[string]string{"max-bitrate": "100k"}, "http://a.com/lq.mp3"},
+		{map[string]string{"max-size": "15M"}, "http://a.com/ep.opus"},
+		{map[string]string{"prefer-codec": "opus"}, "http://a.com/ep.opus"},
+		{map[string]string{"prefer-type": "video/*,audio/*"}, "http://a.com/ep.mp4"},
+		{map[string]string{"prefer-type": "audio/mpeg", "prefer-codec": "opus"}, "http://a.com/ep.opus"},
+	}
+
+	for _, test := range tests {
+		p, err := ParsePreference(test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e, ok := p.Choose(enclosures)
+		if !ok {
+			t.Fatalf("No enclosure chosen for %v", test.opts)
+		}
+
+		if e.URL != test.expected {
+			t.Fatalf("Expected %q - got %q", test.expected, e.URL)
+		}
+	}
+
+	p := &Preference{MaxSize: 1000}
+	if _, ok := p.Choose(enclosures); ok {
+		t.Fail()
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package gpodder implements a client for the subscription and episode
+// action APIs of gpodder.net and compatible servers, e.g. mygpo or the
+// Nextcloud gPodder Sync app.
+// See also: https://gpoddernet.readthedocs.io/en/latest/api/
+package gpodder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nmeum/cpod/util"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default base URL of the gpodder.net API.
+const DefaultURL = "https://gpodder.net"
+
+// Layout of episode action timestamps.
+const timeLayout = "2006-01-02T15:04:05"
+
+// Client is a client for a gpodder.net compatible server.
+type Client struct {
+	// Base URL of the server.
+	BaseURL string
+
+	// Credentials of the user.
+	Username, Password string
+
+	// Identifier of the device subscriptions are synchronized with.
+	Device string
+}
+
+// Changes represents subscription changes since a point in time.
+type Changes struct {
+	// Feed URLs added since the given time.
+	Add []string `json:"add"`
+
+	// Feed URLs removed since the given time.
+	Remove []string `json:"remove"`
+
+	// Server time of the changes, used for the next request.
+	Timestamp int64 `json:"timestamp"`
+}
+
+// Update represents the reply to uploaded changes.
+type Update struct {
+	// Server time of the changes, used for the next request.
+	Timestamp int64 `json:"timestamp"`
+
+	// Pairs of uploaded URLs and their replacement, if the server
+	// rewrote them. An empty replacement indicates that the URL was
+	// rejected.
+	URLs [][2]string `json:"update_urls"`
+}
+
+// EpisodeAction represents an action performed on an episode.
+type EpisodeAction struct {
+	// URL of the podcast feed.
+	Podcast string `json:"podcast"`
+
+	// URL of the episode file.
+	Episode string `json:"episode"`
+
+	// Identifier of the device performing the action.
+	Device string `json:"device,omitempty"`
+
+	// Action, one of download, play, delete or new.
+	Action string `json:"action"`
+
+	// Time the action was performed.
+	Timestamp time.Time `json:"-"`
+}
+
+// MarshalJSON encodes the action, the timestamp is encoded in the
+// format expected by the API.
+func (a EpisodeAction) MarshalJSON() ([]byte, error) {
+	type action EpisodeAction // Prevent recursion
+	return json.Marshal(struct {
+		action
+		Timestamp string `json:"timestamp"`
+	}{action(a), a.Timestamp.UTC().Format(timeLayout)})
+}
+
+// Subscriptions returns the subscription changes of the device since
+// the given server time, zero returns all subscriptions.
+func (c *Client) Subscriptions(ctx context.Context, since int64) (changes Changes, err error) {
+	path := c.path("subscriptions", c.Device) + "?" + url.Values{"since": {strconv.FormatInt(since, 10)}}.Encode()
+	err = c.request(ctx, "GET", path, nil, &changes)
+	return
+}
+
+// UploadChanges uploads the given subscription changes of the device.
+func (c *Client) UploadChanges(ctx context.Context, add, remove []string) (update Update, err error) {
+	changes := Changes{Add: add, Remove: remove}
+	if changes.Add == nil {
+		changes.Add = []string{}
+	}
+	if changes.Remove == nil {
+		changes.Remove = []string{}
+	}
+
+	err = c.request(ctx, "POST", c.path("subscriptions", c.Device), changes, &update)
+	return
+}
+
+// UploadActions uploads the given episode actions, the device of the
+// client is used for actions without a device.
+func (c *Client) UploadActions(ctx context.Context, actions []EpisodeAction) (update Update, err error) {
+	for i := range actions {
+		if len(actions[i].Device) <= 0 {
+			actions[i].Device = c.Device
+		}
+	}
+
+	if actions == nil {
+		actions = []EpisodeAction{}
+	}
+
+	err = c.request(ctx, "POST", c.path("episodes"), actions, &update)
+	return
+}
+
+// path returns the path of the API endpoint with the given name for the
+// user of the client and the given further path elements.
+func (c *Client) path(endpoint string, elems ...string) string {
+	path := "/api/2/" + endpoint + "/" + url.PathEscape(c.Username)
+	for _, elem := range elems {
+		path += "/" + url.PathEscape(elem)
+	}
+
+	return path + ".json"
+}
+
+// request performs an authenticated request with the given value
+// encoded as JSON body, the JSON reply is decoded into out.
+func (c *Client) request(ctx context.Context, method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.BaseURL, "/")+path, &body)
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(c.Username, c.Password)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := util.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, req.URL.Path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,147 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package gpodder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *Client) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "jane" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		handler(w, r)
+	}))
+
+	return ts, &Client{BaseURL: ts.URL + "/", Username: "jane", Password: "secret", Device: "cpod"}
+}
+
+func TestSubscriptions(t *testing.T) {
+	ts, c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/2/subscriptions/jane/cpod.json" {
+			t.Fatalf("Expected %q - got %q", "/api/2/subscriptions/jane/cpod.json", r.URL.Path)
+		}
+
+		if since := r.URL.Query().Get("since"); since != "42" {
+			t.Fatalf("Expected %q - got %q", "42", since)
+		}
+
+		w.Write([]byte(`{"add": ["http://example.com/feed.rss"], "remove": [], "timestamp": 1337}`))
+	})
+	defer ts.Close()
+
+	changes, err := c.Subscriptions(context.Background(), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Changes{Add: []string{"http://example.com/feed.rss"}, Remove: []string{}, Timestamp: 1337}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Expected %v - got %v", expected, changes)
+	}
+}
+
+func TestUploadChanges(t *testing.T) {
+	ts, c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Fatalf("Expected %q - got %q", "POST", r.Method)
+		}
+
+		var changes Changes
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(changes.Add) != 1 || changes.Remove == nil {
+			t.Fatalf("Unexpected changes %v", changes)
+		}
+
+		w.Write([]byte(`{"timestamp": 1338, "update_urls": [["http://example.com/feed.rss ", "http://example.com/feed.rss"]]}`))
+	})
+	defer ts.Close()
+
+	update, err := c.UploadChanges(context.Background(), []string{"http://example.com/feed.rss "}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if update.Timestamp != 1338 {
+		t.Fatalf("Expected %d - got %d", 1338, update.Timestamp)
+	}
+
+	urls := [][2]string{{"http://example.com/feed.rss ", "http://example.com/feed.rss"}}
+	if !reflect.DeepEqual(update.URLs, urls) {
+		t.Fatalf("Expected %q - got %q", urls, update.URLs)
+	}
+}
+
+func TestUploadActions(t *testing.T) {
+	ts, c := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/2/episodes/jane.json" {
+			t.Fatalf("Expected %q - got %q", "/api/2/episodes/jane.json", r.URL.Path)
+		}
+
+		var actions []map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&actions); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []map[string]string{{
+			"podcast":   "http://example.com/feed.rss",
+			"episode":   "http://example.com/1.mp3",
+			"device":    "cpod",
+			"action":    "download",
+			"timestamp": "2009-12-12T09:00:00",
+		}}
+
+		if !reflect.DeepEqual(actions, expected) {
+			t.Fatalf("Expected %v - got %v", expected, actions)
+		}
+
+		w.Write([]byte(`{"timestamp": 1339, "update_urls": []}`))
+	})
+	defer ts.Close()
+
+	actions := []EpisodeAction{{
+		Podcast:   "http://example.com/feed.rss",
+		Episode:   "http://example.com/1.mp3",
+		Action:    "download",
+		Timestamp: time.Date(2009, 12, 12, 10, 0, 0, 0, time.FixedZone("CET", 3600)),
+	}}
+
+	if _, err := c.UploadActions(context.Background(), actions); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	ts, c := newServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer ts.Close()
+
+	c.Password = "wrong"
+	if _, err := c.Subscriptions(context.Background(), 0); err == nil {
+		t.Fatal("Expected error for wrong password")
+	}
+}
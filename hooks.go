@@ -0,0 +1,42 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"github.com/nmeum/cpod/internal/app"
+	"os"
+	"os/exec"
+)
+
+// runHook runs the shell command configured for the hook with the given
+// key, if any. The given variables are added to its environment. Errors
+// are logged since a failing hook shouldn't abort the update.
+func runHook(ctx context.Context, key string, env ...string) {
+	command := cfg.String(key)
+	if len(command) <= 0 {
+		return
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		app.Logger.Printf("%s: %s\n", key, err)
+	}
+}
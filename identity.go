@@ -0,0 +1,113 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// File containing the marker which was stored in the download directory
+// of a podcast by previous versions.
+const legacyMarker = ".latest"
+
+// stateDir returns the directory containing the state of the given
+// podcast.
+func stateDir(p store.Podcast) string {
+	return p.Dir(app.StateDir)
+}
+
+// cacheDir returns the directory containing cached data of the given
+// podcast, e.g. its metadata.
+func cacheDir(p store.Podcast) string {
+	return p.Dir(app.CacheDir)
+}
+
+// migrateState moves the marker stored by previous versions of cpod to
+// the state directory of the given podcast and caches its metadata. It
+// is called by the client once the podcast was identified.
+func migrateState(p store.Podcast) error {
+	dir, err := catcher.Dir(p)
+	if err != nil {
+		return err
+	}
+
+	state := stateDir(p)
+	if err := os.MkdirAll(state, 0755); err != nil {
+		return err
+	}
+
+	if err := moveFiles(dir, state, legacyMarker); err != nil {
+		return err
+	}
+
+	return p.Metadata().Save(cacheDir(p))
+}
+
+// stateDirs returns the state directories of all podcasts identified
+// so far, keyed by the name of their download directory.
+func stateDirs() (map[string]string, error) {
+	files, err := ioutil.ReadDir(app.StateDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]string)
+	for _, f := range files {
+		dir := filepath.Join(app.StateDir, f.Name())
+		data, err := ioutil.ReadFile(filepath.Join(dir, "name"))
+		if name := strings.TrimSpace(string(data)); err == nil && len(name) > 0 {
+			dirs[name] = dir
+		}
+	}
+
+	return dirs, nil
+}
+
+// moveFiles moves the files with the given names from the source to the
+// destination directory, unless they exist in the destination directory
+// already.
+func moveFiles(src, dest string, names ...string) error {
+	for _, name := range names {
+		target := filepath.Join(dest, name)
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+
+		path := filepath.Join(src, name)
+		if err := os.Rename(path, target); err == nil || os.IsNotExist(err) {
+			continue
+		}
+
+		// The directories might be on different file systems.
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		} else if err := ioutil.WriteFile(target, data, 0644); err != nil {
+			return err
+		} else if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package app contains the state shared by all cpod commands, i.e. the
+// resolution of file paths, the database lock and logging.
+package app
+
+import (
+	"fmt"
+	"github.com/nmeum/cpod/paths"
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/cpod/util"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name of the application, used for file names and log messages.
+const Name = "cpod"
+
+var (
+	// Logger for messages of all commands.
+	Logger = log.New(os.Stderr, fmt.Sprintf("%s: ", Name), 0)
+
+	// Name of the selected profile, empty for the default profile.
+	Profile string
+
+	// Directories used by all commands, see SetDirs.
+	Dirs paths.Dirs
+
+	// Directory episodes are downloaded to.
+	DownloadDir string
+
+	// Directory containing the state of all podcasts.
+	StateDir string
+
+	// Directory containing cached data of all podcasts.
+	CacheDir string
+
+	// Path of the URL file.
+	StorePath string
+
+	// Path of the database lock.
+	LockPath string
+
+	// Maximal time to wait for the database lock.
+	LockTimeout time.Duration
+)
+
+func init() {
+	SetDirs(paths.Resolve(Name))
+}
+
+// SetDirs changes the directories used by all commands, e.g. to apply
+// directories given on the command line, and the paths derived from
+// them. It must be called before any other function of this package.
+func SetDirs(dirs paths.Dirs) {
+	Dirs = dirs
+	DownloadDir = dirs.Download
+	StateDir = dirs.State
+	CacheDir = dirs.Cache
+	StorePath = filepath.Join(dirs.Config, "urls")
+	LockPath = filepath.Join(dirs.Runtime, Name+".lock")
+	if len(Profile) > 0 {
+		LockPath = filepath.Join(dirs.Runtime, Name+"-"+Profile+".lock")
+	}
+}
+
+// SetProfile selects the profile with the given name. Each profile uses
+// separate directories, below those of the default profile, and thus
+// has its own URL file, download directory, state and database lock.
+// It must be called before SetDirs.
+func SetProfile(name string) error {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || len(name) <= 0 {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+
+	Profile = name
+	SetDirs(Dirs.Profile(name))
+	return nil
+}
+
+// Process wide state of the database lock. The lock is reference
+// counted, thus it can be shared by multiple goroutines, e.g. the
+// daemon and the HTTP server.
+var (
+	lockMu   sync.Mutex
+	lockRefs int
+	lockFile *util.Lockfile
+)
+
+// storeMu serializes modifications of the URL file in this process.
+var storeMu sync.Mutex
+
+// Modification time of the URL file after it was saved by this process
+// last, protected by storeMu.
+var savedTime time.Time
+
+// AcquireLock acquires the database lock or increments its reference
+// count if it is already held by this process.
+func AcquireLock() error {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockRefs == 0 {
+		if err := os.MkdirAll(filepath.Dir(LockPath), 0700); err != nil {
+			return err
+		}
+
+		l, err := util.LockWait(LockPath, LockTimeout)
+		if os.IsExist(err) {
+			owner, _ := util.LockOwner(LockPath)
+			return fmt.Errorf("database is locked by process %q, see %q", owner, LockPath)
+		} else if err != nil {
+			return err
+		}
+
+		lockFile = l
+	}
+
+	lockRefs++
+	return nil
+}
+
+// ReleaseLock decrements the reference count of the database lock and
+// releases the lock if it is no longer used.
+func ReleaseLock() error {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockRefs--; lockRefs == 0 {
+		return lockFile.Unlock()
+	}
+
+	return nil
+}
+
+// LoadStore loads the URL file without acquiring the database lock. A
+// missing URL file results in an empty store.
+func LoadStore() (*store.Store, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	storage, err := store.Load(StorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// WithLock acquires the database lock, loads the store and passes it
+// to the given function. The lock is released after the function
+// returned.
+func WithLock(fn func(*store.Store) error) error {
+	if err := AcquireLock(); err != nil {
+		return err
+	}
+
+	storeMu.Lock()
+	storage, err := store.Load(StorePath)
+	storeMu.Unlock()
+
+	if err == nil {
+		err = fn(storage)
+	}
+
+	if uerr := ReleaseLock(); err == nil {
+		err = uerr
+	}
+
+	return err
+}
+
+// ModifyStore acquires the database lock, loads the store, passes it
+// to the given function and saves it afterwards. A missing URL file is
+// created.
+func ModifyStore(fn func(*store.Store) error) error {
+	if err := AcquireLock(); err != nil {
+		return err
+	}
+	defer ReleaseLock()
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	storage, err := store.Load(StorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := fn(storage); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(StorePath), 0755); err != nil {
+		return err
+	} else if err := storage.Save(); err != nil {
+		return err
+	}
+
+	if fi, err := os.Stat(StorePath); err == nil {
+		savedTime = fi.ModTime()
+	}
+
+	return nil
+}
+
+// SavedTime returns the modification time of the URL file after it was
+// saved by ModifyStore last. It allows distinguishing modifications of
+// this process from those of other processes.
+func SavedTime() time.Time {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	return savedTime
+}
+
+// Feeds provides access to the URL file for the podcatcher package, it
+// is modified while holding the database lock.
+type Feeds struct{}
+
+// Load loads the URL file, see LoadStore.
+func (Feeds) Load() (*store.Store, error) {
+	return LoadStore()
+}
+
+// Modify modifies the URL file, see ModifyStore.
+func (Feeds) Modify(fn func(*store.Store) error) error {
+	return ModifyStore(fn)
+}
@@ -16,202 +16,246 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/paths"
+	"github.com/nmeum/cpod/podcatcher"
 	"github.com/nmeum/cpod/store"
-	"github.com/nmeum/cpod/util"
-	"github.com/nmeum/go-feedparser"
-	"log"
 	"os"
-	"path/filepath"
-	"sync"
-	"time"
+	"os/signal"
+	"syscall"
 )
 
-const (
-	appName    = "cpod"
-	appVersion = "1.9"
-)
-
-var (
-	limit   = flag.Int("p", 5, "number of maximal parallel downloads")
-	recent  = flag.Int("r", 0, "number of most recent episodes to download")
-	version = flag.Bool("v", false, "display version number and exit")
-)
+const appVersion = "1.9"
 
 var (
-	logger      = log.New(os.Stderr, fmt.Sprintf("%s: ", appName), 0)
-	downloadDir = util.EnvDefault("CPOD_DOWNLOAD_DIR", "podcasts")
+	chapters    = flag.Bool("c", false, "download chapters of episodes")
+	move        = flag.Bool("m", false, "update URLs of permanently moved feeds")
+	limit       = flag.Int("p", 5, "number of maximal parallel downloads")
+	recent      = flag.Int("r", 0, "number of most recent episodes to download")
+	transcripts = flag.Bool("t", false, "download transcripts of episodes")
+	version     = flag.Bool("v", false, "display version number and exit")
+	wait        = flag.Duration("w", 0, "maximal time to wait for the database lock")
+	profile     = flag.String("profile", "", "name of the profile to use")
+	configDir   = flag.String("config-dir", "", "directory containing the URL and configuration file")
 )
 
-func main() {
-	flag.Parse()
-	if *version {
-		logger.Fatal(appVersion)
-	}
-
-	storeDir := filepath.Join(util.EnvDefault("XDG_CONFIG_HOME", ".config"), appName)
-	lockPath := filepath.Join(os.TempDir(), fmt.Sprintf("%s-%s", appName, util.Username()))
-
-	if err := util.Lock(lockPath); os.IsExist(err) {
-		logger.Fatalf("database is locked, remove %q to force unlock\n", lockPath)
-	} else if err != nil {
-		logger.Fatal(err)
-	}
-
-	storage, err := store.Load(filepath.Join(storeDir, "urls"))
-	if err != nil {
-		logger.Fatal(err)
-	}
-
-	update(storage)
-	if err := os.Remove(lockPath); err != nil {
-		logger.Fatal(err)
-	}
+func init() {
+	// Other directories are part of the configuration, see settings.
+	flag.String("state-dir", "", "directory containing the download history")
+	flag.String("cache-dir", "", "directory containing cached feed metadata")
+	flag.String("runtime-dir", "", "directory containing the database lock")
+	flag.String("download-dir", "", "directory episodes are downloaded to")
 }
 
-func update(storage *store.Store) {
-	var wg sync.WaitGroup
-	var counter int
-
-	for cast := range storage.Fetch() {
-		wg.Add(1)
-		counter++
-
-		go func(p store.Podcast) {
-			defer func() {
-				wg.Done()
-				counter--
-			}()
-
-			feed := p.Feed
-			if p.Error != nil {
-				logger.Println(p.Error)
-				return
-			}
-
-			items, err := newItems(feed)
-			if err != nil {
-				logger.Println(err)
-				return
-			}
-
-			for i := len(items) - 1; i >= 0; i-- {
-				item := items[i]
-				if err := getItem(feed, item); err != nil {
-					logger.Println(err)
-					break
-				}
-
-				if err := writeMarker(feed.Title, item.PubDate); err != nil {
-					logger.Println(err)
-					break
-				}
-			}
-		}(cast)
-
-		for *limit > 0 && counter >= *limit {
-			time.Sleep(3 * time.Second)
-		}
-	}
+// Client used by all commands, created once the configuration was
+// loaded.
+var catcher *podcatcher.Client
+
+// Commands which can be passed as the first argument, if no command
+// is given all feeds are updated.
+var commands = map[string]func(context.Context, *store.Store, []string) error{
+	"backfill": backfill,
+	"catchup":  catchup,
+	"feed":     feed,
+	"skip":     skip,
+	"unskip":   unskip,
+}
 
-	wg.Wait()
+// Commands which don't require the store, they acquire the database
+// lock themselves if needed.
+var standalone = map[string]func(context.Context, []string) error{
+	"add":    add,
+	"config": configCmd,
+	"daemon": daemon,
+	"export": exportSubs,
+	"import": importSubs,
+	"search": search,
+	"sync":   syncGpodder,
 }
 
-func newItems(cast feedparser.Feed) (items []feedparser.Item, err error) {
-	unread, err := readMarker(cast.Title)
-	if os.IsNotExist(err) {
-		err = nil
-	} else if err != nil {
-		return
-	}
+func usage() {
+	fmt.Fprintf(os.Stderr, "USAGE: %s [FLAGS] [COMMAND [ARGS...]]\n\n", app.Name)
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	fmt.Fprintf(os.Stderr, "  add URL [KEY=VALUE...]\n")
+	fmt.Fprintf(os.Stderr, "  backfill FEED [--since DATE | --all | --count N]\n")
+	fmt.Fprintf(os.Stderr, "  catchup [FEED]\n")
+	fmt.Fprintf(os.Stderr, "  config show\n")
+	fmt.Fprintf(os.Stderr, "  daemon [-i INTERVAL] [-l ADDRESS] [-b URL] [-u URL]\n")
+	fmt.Fprintf(os.Stderr, "  export [-f FORMAT] [FILE]\n")
+	fmt.Fprintf(os.Stderr, "  feed [-b URL]\n")
+	fmt.Fprintf(os.Stderr, "  import [-f FORMAT] [-dry-run] [-category NAME] [-i] FILE...\n")
+	fmt.Fprintf(os.Stderr, "  search [-d DIRECTORY] TERM...\n")
+	fmt.Fprintf(os.Stderr, "  skip FEED GUID|PATTERN\n")
+	fmt.Fprintf(os.Stderr, "  sync\n")
+	fmt.Fprintf(os.Stderr, "  unskip FEED GUID|PATTERN\n\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
 
-	if *recent > 0 && len(cast.Items) >= *recent {
-		cast.Items = cast.Items[0:*recent]
+// selectProfile selects the profile given by the -profile flag or the
+// CPOD_PROFILE environment variable and applies the -config-dir flag.
+func selectProfile() error {
+	name := *profile
+	if len(name) <= 0 {
+		name = os.Getenv("CPOD_PROFILE")
 	}
 
-	for _, item := range cast.Items {
-		if !item.PubDate.After(unread) {
-			break
+	if len(name) > 0 {
+		if err := app.SetProfile(name); err != nil {
+			return err
 		}
+	}
 
-		if len(item.Attachment) > 0 {
-			items = append(items, item)
-		}
+	if len(*configDir) > 0 {
+		dirs := app.Dirs
+		dirs.Config = *configDir
+		app.SetDirs(dirs)
 	}
 
-	return
+	return nil
 }
 
-func getItem(cast feedparser.Feed, item feedparser.Item) error {
-	title, err := util.Escape(cast.Title)
-	if err != nil {
-		return err
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if *version {
+		app.Logger.Fatal(appVersion)
+	}
+
+	if err := selectProfile(); err != nil {
+		app.Logger.Fatal(err)
+	} else if err := loadConfig(); err != nil {
+		app.Logger.Fatal(err)
 	}
 
-	target := filepath.Join(downloadDir, title)
-	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-		return err
+	app.LockTimeout = *wait
+	app.SetDirs(paths.Dirs{
+		Config:   app.Dirs.Config,
+		State:    cfg.String("paths.state"),
+		Cache:    cfg.String("paths.cache"),
+		Runtime:  cfg.String("paths.runtime"),
+		Download: cfg.String("paths.download"),
+	})
+
+	var err error
+	if catcher, err = newClient(); err != nil {
+		app.Logger.Fatal(err)
 	}
 
-	fp, err := util.GetFile(item.Attachment, target)
-	if err != nil {
-		return err
+	run := func(ctx context.Context) error {
+		return app.WithLock(func(storage *store.Store) error {
+			catcher.UpdateStore(ctx, storage)
+			return ctx.Err()
+		})
 	}
 
-	name, err := util.Escape(item.Title)
-	if err == nil {
-		newfp := filepath.Join(target, name+filepath.Ext(fp))
-		if err = os.Rename(fp, newfp); err != nil {
-			return err
+	if args := flag.Args(); len(args) > 0 {
+		name, args := args[0], args[1:]
+		if cmd, ok := commands[name]; ok {
+			run = func(ctx context.Context) error {
+				return app.WithLock(func(storage *store.Store) error {
+					if err := cmd(ctx, storage, args); err != nil {
+						return err
+					}
+
+					return ctx.Err()
+				})
+			}
+		} else if cmd, ok := standalone[name]; ok {
+			run = func(ctx context.Context) error {
+				return cmd(ctx, args)
+			}
+		} else {
+			app.Logger.Fatalf("unknown command %q\n", name)
 		}
 	}
 
-	return nil
-}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ch := make(chan os.Signal, 2)
+		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
 
-func readMarker(name string) (marker time.Time, err error) {
-	name, err = util.Escape(name)
-	if err != nil {
-		return
-	}
+		<-ch // Stop starting new downloads on first signal
+		app.Logger.Println("finishing, send signal again to exit immediately")
+		cancel()
 
-	file, err := os.Open(filepath.Join(downloadDir, name, ".latest"))
-	if err != nil {
-		return
-	}
+		<-ch // Force exit on second signal
+		os.Exit(2)
+	}()
 
-	defer file.Close()
-	var timestamp int64
+	if err := run(ctx); err != nil {
+		if ctx.Err() != nil {
+			os.Exit(2) // Errors caused by cancellation are expected
+		}
 
-	if _, err = fmt.Fscanf(file, "%d\n", &timestamp); err != nil {
-		return
+		app.Logger.Fatal(err)
 	}
-
-	marker = time.Unix(timestamp, 0)
-	return
 }
 
-func writeMarker(name string, latest time.Time) error {
-	name, err := util.Escape(name)
-	if err != nil {
-		return err
+// newClient returns a client for the URL file and the directories of
+// the selected profile which is configured according to cfg.
+func newClient() (*podcatcher.Client, error) {
+	c := podcatcher.New(podcatcher.Options{
+		DownloadDir:   app.DownloadDir,
+		Concurrency:   *limit,
+		Recent:        *recent,
+		Chapters:      *chapters,
+		Transcripts:   *transcripts,
+		Move:          *move,
+		DirectoryName: cfg.String("naming.directory"),
+		EpisodeName:   cfg.String("naming.episode"),
+		Keep:          cfg.Int("retention.keep"),
+		MaxAge:        cfg.Duration("retention.max_age"),
+		Identified:    migrateState,
+	}, app.Feeds{}, podcatcher.HTTPDownloader{}, podcatcher.DirHistory{Dir: app.StateDir})
+
+	if err := c.CheckNames(); err != nil {
+		return nil, err
 	}
 
-	path := filepath.Join(downloadDir, name, ".latest")
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return err
-	}
+	c.On(handleEvent)
+	return c, nil
+}
 
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
+// handleEvent tracks the activity of the client, logs errors and runs
+// the configured hooks.
+func handleEvent(e podcatcher.Event) {
+	switch e.Type {
+	case podcatcher.Queued:
+		activity.enqueue([]string{e.URL})
+	case podcatcher.Fetched:
+		activity.dequeue(e.URL)
+	case podcatcher.Started:
+		activity.start(e.Podcast.Feed.Title, e.Item.Title, e.Item.Attachment)
+	case podcatcher.Downloaded:
+		activity.done(e.Item.Attachment)
+		if e.Err != nil {
+			return // Reported by the caller of Download
+		}
 
-	defer file.Close()
-	if _, err := fmt.Fprintf(file, "%d\n", latest.Unix()); err != nil {
-		return err
+		runHook(context.Background(), "hooks.post_download",
+			"CPOD_FILE="+e.Path,
+			"CPOD_FEED="+e.URL,
+			"CPOD_PODCAST="+e.Podcast.Feed.Title,
+			"CPOD_EPISODE="+e.Item.Title,
+			"CPOD_EPISODE_URL="+e.Item.Attachment)
+	case podcatcher.Removed:
+		app.Logger.Printf("removed %s\n", e.Path)
+	case podcatcher.Moved:
+		if !*move {
+			app.Logger.Printf("%s moved permanently to %s, use -m to update it\n", e.URL, e.Podcast.Moved)
+		} else if e.Err == nil {
+			app.Logger.Printf("%s → %s\n", e.URL, e.Podcast.Moved)
+		}
+	case podcatcher.Finished:
+		activity.finish()
+		runHook(context.Background(), "hooks.post_update", fmt.Sprintf("CPOD_DOWNLOADED=%d", e.Downloaded))
 	}
 
-	return nil
+	if e.Err != nil {
+		app.Logger.Println(e.Err)
+	}
 }
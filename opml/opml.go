@@ -20,7 +20,9 @@ package opml
 import (
 	"encoding/xml"
 	"golang.org/x/net/html/charset"
+	"io"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -35,14 +37,47 @@ type OPML struct {
 	// OPML standard version implemented by this file.
 	Version string `xml:"version,attr"`
 
+	// Metadata of the document.
+	Head Head `xml:"head"`
+
+	// Attributes of the opml element not covered by other fields.
+	Attrs []xml.Attr `xml:",any,attr"`
+
+	// Array of top-level outlines, each represents a subscription or
+	// a folder of subscriptions.
+	Outlines []Outline `xml:"body>outline"`
+}
+
+// Head represents the head element of an OPML document.
+type Head struct {
 	// Title of the OPML document.
-	Title string `xml:"head>title"`
+	Title string `xml:"title"`
 
 	// Time the document was created.
-	Created string `xml:"head>dateCreated"`
+	Created string `xml:"dateCreated"`
 
-	// Array of outlines, each represents a subscription.
-	Outlines []Outline `xml:"body>outline"`
+	// Time the document was last modified.
+	Modified string `xml:"dateModified,omitempty"`
+
+	// Name and email address of the document owner.
+	OwnerName  string `xml:"ownerName,omitempty"`
+	OwnerEmail string `xml:"ownerEmail,omitempty"`
+
+	// Elements not covered by other fields, e.g. expansionState or
+	// windowTop. They are retained to write them back unmodified.
+	Elements []Element `xml:",any"`
+}
+
+// Element represents an arbitrary XML element.
+type Element struct {
+	// XML name.
+	XMLName xml.Name
+
+	// Attributes of the element.
+	Attrs []xml.Attr `xml:",any,attr"`
+
+	// Raw XML content of the element.
+	Content string `xml:",innerxml"`
 }
 
 // Outline represents an arbitrary OPML outline.
@@ -50,11 +85,38 @@ type Outline struct {
 	// Text attribute, might contain HTML markup.
 	Text string `xml:"text,attr"`
 
+	// Title of the feed, usually equal to the text attribute.
+	Title string `xml:"title,attr,omitempty"`
+
 	// Type of file found at the outline URL.
-	Type string `xml:"type,attr"`
+	Type string `xml:"type,attr,omitempty"`
 
 	// Arbitrary outline URL.
-	URL string `xml:"xmlUrl,attr"`
+	URL string `xml:"xmlUrl,attr,omitempty"`
+
+	// URL of the website the feed belongs to.
+	HTMLURL string `xml:"htmlUrl,attr,omitempty"`
+
+	// Description of the feed.
+	Description string `xml:"description,attr,omitempty"`
+
+	// Language of the feed.
+	Language string `xml:"language,attr,omitempty"`
+
+	// Version of the feed format, e.g. RSS2.
+	Version string `xml:"version,attr,omitempty"`
+
+	// Comma separated list of slash delimited category paths.
+	Category string `xml:"category,attr,omitempty"`
+
+	// Time the outline was created.
+	Created string `xml:"created,attr,omitempty"`
+
+	// Attributes not covered by other fields.
+	Attrs []xml.Attr `xml:",any,attr"`
+
+	// Array of nested outlines.
+	Outlines []Outline `xml:"outline"`
 }
 
 // Create returns a new OPML document with the given title. However,
@@ -63,29 +125,113 @@ type Outline struct {
 func Create(title string) *OPML {
 	return &OPML{
 		Version: version,
-		Title:   title,
-		Created: time.Now().Format(time.RFC1123Z),
+		Head: Head{
+			Title:   title,
+			Created: time.Now().Format(time.RFC1123Z),
+		},
 	}
 }
 
 // Load reads an existing OPML document located at the given path.
-func Load(path string) (o *OPML, err error) {
+func Load(path string) (*OPML, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return
+		return nil, err
 	}
 	defer file.Close()
 
-	decoder := xml.NewDecoder(file)
+	return Decode(file)
+}
+
+// Decode reads an OPML document from the given reader.
+func Decode(r io.Reader) (o *OPML, err error) {
+	decoder := xml.NewDecoder(r)
 	decoder.CharsetReader = charset.NewReaderLabel
 
 	if err = decoder.Decode(&o); err != nil {
 		return
 	}
 
+	// Namespace declarations are not retained, the name of namespaced
+	// attributes contains the namespace URL and the encoder declares
+	// the namespace again.
+	o.Attrs = stripNamespaces(o.Attrs)
+	for i := range o.Head.Elements {
+		o.Head.Elements[i].Attrs = stripNamespaces(o.Head.Elements[i].Attrs)
+	}
+	o.Walk(func(parents []string, outline *Outline) bool {
+		outline.Attrs = stripNamespaces(outline.Attrs)
+		return true
+	})
+
 	return
 }
 
+func stripNamespaces(attrs []xml.Attr) []xml.Attr {
+	var stripped []xml.Attr
+	for _, attr := range attrs {
+		if attr.Name.Space != "xmlns" && !(attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			stripped = append(stripped, attr)
+		}
+	}
+
+	return stripped
+}
+
+// IsFeed returns true if the outline references a feed. Outlines which
+// don't are usually folders or comments.
+func (o Outline) IsFeed() bool {
+	return len(strings.TrimSpace(o.URL)) > 0
+}
+
+// Categories returns the category paths of the outline with leading
+// and trailing slashes removed.
+func (o Outline) Categories() []string {
+	var categories []string
+	for _, c := range strings.Split(o.Category, ",") {
+		if c = strings.Trim(strings.TrimSpace(c), "/"); len(c) > 0 {
+			categories = append(categories, c)
+		}
+	}
+
+	return categories
+}
+
+// Walk calls fn for every outline of the document in document order.
+// The texts of all enclosing outlines are passed to fn as well. The
+// outline can be modified through the given pointer. If fn returns
+// false, the nested outlines of the outline are skipped.
+func (o *OPML) Walk(fn func(parents []string, outline *Outline) bool) {
+	walk(o.Outlines, nil, fn)
+}
+
+func walk(outlines []Outline, parents []string, fn func([]string, *Outline) bool) {
+	for i := range outlines {
+		outline := &outlines[i]
+		if fn(parents, outline) {
+			// Copy parents to prevent fn from retaining a slice which
+			// is modified by subsequent calls.
+			path := append(append([]string(nil), parents...), outline.Text)
+			walk(outline.Outlines, path, fn)
+		}
+	}
+}
+
+// Feeds returns all outlines of the document which reference a feed,
+// regardless of their nesting, in document order.
+func (o *OPML) Feeds() []Outline {
+	var feeds []Outline
+	o.Walk(func(parents []string, outline *Outline) bool {
+		if outline.IsFeed() {
+			feeds = append(feeds, *outline)
+		}
+
+		return true
+	})
+
+	return feeds
+}
+
 // Add appends a new outline to the OPML document, even if the outline
 // is already a part of the document.
 func (o *OPML) Add(text, ftype, url string) {
@@ -107,18 +253,24 @@ func (o *OPML) Save(path string) error {
 	}
 
 	defer file.Close()
+	return o.Write(file)
+}
+
+// Write writes an indented version of the OPML document to the given
+// writer.
+func (o *OPML) Write(w io.Writer) error {
 	data, err := xml.MarshalIndent(o, "", "\t")
 	if err != nil {
 		return err
 	}
 
-	if _, err = file.WriteString(xml.Header); err != nil {
+	if _, err = io.WriteString(w, xml.Header); err != nil {
 		return err
 	}
 
-	if _, err = file.Write(data); err != nil {
+	if _, err = w.Write(append(data, '\n')); err != nil {
 		return err
 	}
 
-	return err
+	return nil
 }
@@ -16,15 +16,18 @@
 package opml
 
 import (
+	"encoding/xml"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestCreate(t *testing.T) {
 	o := Create("Test subscriptions")
-	if o.Title != "Test subscriptions" {
-		t.Fatalf("Expected %q - got %q", "Test subscriptions", o.Title)
+	if o.Head.Title != "Test subscriptions" {
+		t.Fatalf("Expected %q - got %q", "Test subscriptions", o.Head.Title)
 	}
 
 	if o.Version != version {
@@ -48,7 +51,7 @@ func TestLoad(t *testing.T) {
 		t.Fatalf("Expected %d - got %d", 1, len(o.Outlines))
 	}
 
-	if o.Outlines[0] != outline {
+	if !reflect.DeepEqual(o.Outlines[0], outline) {
 		t.Fatalf("Expected %q - got %q", outline, o.Outlines[0])
 	}
 
@@ -56,12 +59,12 @@ func TestLoad(t *testing.T) {
 		t.Fatalf("Expected %q - got %q", "2.0", o.Version)
 	}
 
-	if o.Title != "Subscriptions" {
-		t.Fatalf("Expected %q - got %q", "Subscriptions", o.Title)
+	if o.Head.Title != "Subscriptions" {
+		t.Fatalf("Expected %q - got %q", "Subscriptions", o.Head.Title)
 	}
 
-	if o.Created != "Wed, 15 May 2013 19:30:58 +0200" {
-		t.Fatalf("Expected %q - got %q", "Wed, 15 May 2013 19:30:58 +0200", o.Created)
+	if o.Head.Created != "Wed, 15 May 2013 19:30:58 +0200" {
+		t.Fatalf("Expected %q - got %q", "Wed, 15 May 2013 19:30:58 +0200", o.Head.Created)
 	}
 }
 
@@ -75,7 +78,7 @@ func TestAdd(t *testing.T) {
 	o := new(OPML)
 	o.Add("Testcast", "atom", "http://testcast.com/atom-feed.xml")
 
-	if o.Outlines[0] != testOutline {
+	if !reflect.DeepEqual(o.Outlines[0], testOutline) {
 		t.Fatalf("Expected %q - got %q", testOutline, o.Outlines[0])
 	}
 
@@ -99,7 +102,172 @@ func TestSave(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if loaded.Title != "Podcasts" {
+	if loaded.Head.Title != "Podcasts" {
 		t.Fatal(err)
 	}
 }
+
+func TestHead(t *testing.T) {
+	o, err := Load("testdata/testHead.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPath := filepath.Join(os.TempDir(), "testHead.opml")
+	if err := o.Save(testPath); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testPath)
+
+	loaded, err := Load(testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Head.Title != "Podcasts" {
+		t.Fatalf("Expected %q - got %q", "Podcasts", loaded.Head.Title)
+	}
+
+	names := []string{"docs", "expansionState", "ownerId", "windowTop", "generator"}
+	if len(loaded.Head.Elements) != len(names) {
+		t.Fatalf("Expected %d - got %d", len(names), len(loaded.Head.Elements))
+	}
+
+	for i, e := range loaded.Head.Elements {
+		if e.XMLName.Local != names[i] {
+			t.Fatalf("Expected %q - got %q", names[i], e.XMLName.Local)
+		}
+	}
+
+	if !reflect.DeepEqual(loaded.Head.Elements, o.Head.Elements) {
+		t.Fatalf("Expected %v - got %v", o.Head.Elements, loaded.Head.Elements)
+	}
+
+	generator := loaded.Head.Elements[4]
+	if generator.Content != "Some <b>client</b>" {
+		t.Fatalf("Expected %q - got %q", "Some <b>client</b>", generator.Content)
+	}
+}
+
+func TestLoadNested(t *testing.T) {
+	o, err := Load("testdata/testNested.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(o.Outlines) != 2 {
+		t.Fatalf("Expected %d - got %d", 2, len(o.Outlines))
+	}
+
+	if o.Head.OwnerName != "Jane Doe" {
+		t.Fatalf("Expected %q - got %q", "Jane Doe", o.Head.OwnerName)
+	}
+
+	daily := o.Outlines[0].Outlines[0]
+	if daily.Title != "The Daily" {
+		t.Fatalf("Expected %q - got %q", "The Daily", daily.Title)
+	}
+
+	if daily.HTMLURL != "http://example.com/" {
+		t.Fatalf("Expected %q - got %q", "http://example.com/", daily.HTMLURL)
+	}
+
+	if daily.Language != "en" {
+		t.Fatalf("Expected %q - got %q", "en", daily.Language)
+	}
+
+	attrs := []xml.Attr{
+		{Name: xml.Name{Space: "http://overcast.fm/", Local: "id"}, Value: "42"},
+		{Name: xml.Name{Local: "foo"}, Value: "bar"},
+	}
+
+	if !reflect.DeepEqual(daily.Attrs, attrs) {
+		t.Fatalf("Expected %v - got %v", attrs, daily.Attrs)
+	}
+
+	if len(o.Attrs) != 0 {
+		t.Fatalf("Expected %d - got %d", 0, len(o.Attrs))
+	}
+}
+
+func TestFeeds(t *testing.T) {
+	o, err := Load("testdata/testNested.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"http://example.com/daily.rss",
+		"http://example.org/city.rss",
+		"http://chaosradio.ccc.de/chaosradio-latest.rss",
+	}
+
+	feeds := o.Feeds()
+	if len(feeds) != len(expected) {
+		t.Fatalf("Expected %d - got %d", len(expected), len(feeds))
+	}
+
+	for i, feed := range feeds {
+		if feed.URL != expected[i] {
+			t.Fatalf("Expected %q - got %q", expected[i], feed.URL)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	o, err := Load("testdata/testNested.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	o.Walk(func(parents []string, outline *Outline) bool {
+		paths = append(paths, strings.Join(append(parents, outline.Text), "/"))
+		return outline.Text != "Local"
+	})
+
+	expected := []string{"News", "News/Daily", "News/Local", "Chaosradio"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("Expected %q - got %q", expected, paths)
+	}
+}
+
+func TestCategories(t *testing.T) {
+	o, err := Load("testdata/testNested.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Local", "News/Politics"}
+	categories := o.Outlines[0].Outlines[1].Outlines[0].Categories()
+
+	if !reflect.DeepEqual(categories, expected) {
+		t.Fatalf("Expected %q - got %q", expected, categories)
+	}
+
+	if c := o.Outlines[1].Categories(); len(c) != 0 {
+		t.Fatalf("Expected %d - got %d", 0, len(c))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	o, err := Load("testdata/testNested.opml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPath := filepath.Join(os.TempDir(), "testRoundTrip.opml")
+	if err := o.Save(testPath); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(testPath)
+
+	loaded, err := Load(testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(loaded, o) {
+		t.Fatalf("Expected %v - got %v", o, loaded)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package paths resolves the directories used by an application
+// according to the XDG Base Directory Specification.
+package paths
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// Dirs contains the directories used by an application.
+type Dirs struct {
+	// Directory containing configuration, e.g. the URL file.
+	Config string
+
+	// Directory containing state which should persist between runs,
+	// e.g. the download history.
+	State string
+
+	// Directory containing data which can be recreated if it is lost,
+	// e.g. feed metadata.
+	Cache string
+
+	// Directory containing runtime files, e.g. the lockfile.
+	Runtime string
+
+	// Directory episodes are downloaded to.
+	Download string
+}
+
+// Resolve returns the directories of the application with the given
+// name. The download directory is read from the NAME_DOWNLOAD_DIR
+// environment variable, if it isn't set it is a podcasts directory
+// below XDG_MUSIC_DIR or the home directory.
+func Resolve(name string) Dirs {
+	download := os.Getenv(strings.ToUpper(name) + "_DOWNLOAD_DIR")
+	if len(download) <= 0 {
+		download = filepath.Join(Base("XDG_MUSIC_DIR", ""), "podcasts")
+	}
+
+	runtime := Base("XDG_RUNTIME_DIR", "")
+	if !filepath.IsAbs(os.Getenv("XDG_RUNTIME_DIR")) {
+		runtime = filepath.Join(os.TempDir(), fmt.Sprintf("%s-runtime-%s", name, username()))
+	}
+
+	return Dirs{
+		Config:   filepath.Join(Base("XDG_CONFIG_HOME", ".config"), name),
+		State:    filepath.Join(Base("XDG_STATE_HOME", filepath.Join(".local", "state")), name),
+		Cache:    filepath.Join(Base("XDG_CACHE_HOME", ".cache"), name),
+		Runtime:  runtime,
+		Download: download,
+	}
+}
+
+// Profile returns the directories of the profile with the given name.
+// They are located in a profiles directory below the given directories,
+// except for the download directory which is suffixed with the name of
+// the profile, and the runtime directory which is shared.
+func (d Dirs) Profile(name string) Dirs {
+	return Dirs{
+		Config:   filepath.Join(d.Config, "profiles", name),
+		State:    filepath.Join(d.State, "profiles", name),
+		Cache:    filepath.Join(d.Cache, "profiles", name),
+		Runtime:  d.Runtime,
+		Download: d.Download + "-" + name,
+	}
+}
+
+// Base returns the base directory specified by the given environment
+// variable. If the variable isn't set or contains a relative path,
+// which the specification requires to be ignored, the fallback joined
+// with the home directory of the user is returned.
+func Base(key, fallback string) string {
+	dir := os.Getenv(key)
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+
+	return filepath.Join(Home(), fallback)
+}
+
+// Home returns the home directory of the current user.
+func Home() string {
+	user, err := user.Current()
+	if err == nil && len(user.HomeDir) > 0 {
+		return user.HomeDir
+	}
+
+	return os.Getenv("HOME")
+}
+
+// username returns the name of the current user.
+func username() string {
+	user, err := user.Current()
+	if err == nil {
+		return user.Username
+	}
+
+	return os.Getenv("USER")
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setenv(t *testing.T, env map[string]string) {
+	for key, value := range env {
+		old, ok := os.LookupEnv(key)
+		if err := os.Setenv(key, value); err != nil {
+			t.Fatal(err)
+		}
+
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	setenv(t, map[string]string{
+		"XDG_CONFIG_HOME":   "/xdg/config",
+		"XDG_STATE_HOME":    "/xdg/state",
+		"XDG_CACHE_HOME":    "/xdg/cache",
+		"XDG_RUNTIME_DIR":   "/xdg/runtime",
+		"XDG_MUSIC_DIR":     "/xdg/music",
+		"TEST_DOWNLOAD_DIR": "",
+	})
+
+	expected := Dirs{
+		Config:   "/xdg/config/test",
+		State:    "/xdg/state/test",
+		Cache:    "/xdg/cache/test",
+		Runtime:  "/xdg/runtime",
+		Download: "/xdg/music/podcasts",
+	}
+
+	if dirs := Resolve("test"); dirs != expected {
+		t.Fatalf("Expected %v - got %v", expected, dirs)
+	}
+}
+
+func TestResolveDownload(t *testing.T) {
+	setenv(t, map[string]string{
+		"XDG_MUSIC_DIR":     "/xdg/music",
+		"TEST_DOWNLOAD_DIR": "/downloads",
+	})
+
+	if dir := Resolve("test").Download; dir != "/downloads" {
+		t.Fatalf("Expected %q - got %q", "/downloads", dir)
+	}
+}
+
+func TestResolveFallback(t *testing.T) {
+	setenv(t, map[string]string{
+		"XDG_CONFIG_HOME":   "relative",
+		"XDG_STATE_HOME":    "",
+		"XDG_CACHE_HOME":    "",
+		"XDG_RUNTIME_DIR":   "",
+		"XDG_MUSIC_DIR":     "",
+		"TEST_DOWNLOAD_DIR": "",
+	})
+
+	home := Home()
+	expected := Dirs{
+		Config:   filepath.Join(home, ".config", "test"),
+		State:    filepath.Join(home, ".local", "state", "test"),
+		Cache:    filepath.Join(home, ".cache", "test"),
+		Download: filepath.Join(home, "podcasts"),
+	}
+
+	dirs := Resolve("test")
+	if filepath.Dir(dirs.Runtime) != filepath.Clean(os.TempDir()) {
+		t.Fatalf("Expected %q - got %q", os.TempDir(), filepath.Dir(dirs.Runtime))
+	}
+
+	dirs.Runtime = ""
+	if dirs != expected {
+		t.Fatalf("Expected %v - got %v", expected, dirs)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	dirs := Dirs{
+		Config:   "/config/test",
+		State:    "/state/test",
+		Cache:    "/cache/test",
+		Runtime:  "/runtime",
+		Download: "/music/podcasts",
+	}
+
+	expected := Dirs{
+		Config:   "/config/test/profiles/kids",
+		State:    "/state/test/profiles/kids",
+		Cache:    "/cache/test/profiles/kids",
+		Runtime:  "/runtime",
+		Download: "/music/podcasts-kids",
+	}
+
+	if p := dirs.Profile("kids"); p != expected {
+		t.Fatalf("Expected %v - got %v", expected, p)
+	}
+}
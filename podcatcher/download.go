@@ -0,0 +1,326 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"context"
+	"fmt"
+	"github.com/nmeum/cpod/extension"
+	"github.com/nmeum/cpod/filter"
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/cpod/util"
+	"github.com/nmeum/go-feedparser"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Mapping of transcript MIME types to file extensions.
+var transcriptExts = map[string]string{
+	"application/json":     ".json",
+	"application/srt":      ".srt",
+	"application/x-subrip": ".srt",
+	"text/html":            ".html",
+	"text/plain":           ".txt",
+	"text/vtt":             ".vtt",
+}
+
+// Downloader downloads files.
+type Downloader interface {
+	// Download downloads the file at the given URL to the given
+	// directory and returns the path of the downloaded file.
+	Download(ctx context.Context, uri, dir string) (string, error)
+}
+
+// HTTPDownloader downloads files using HTTP, interrupted downloads are
+// resumed. The file is named like the last element of the URL path.
+type HTTPDownloader struct{}
+
+// Download implements the Downloader interface.
+func (HTTPDownloader) Download(ctx context.Context, uri, dir string) (string, error) {
+	return util.GetFile(ctx, uri, dir)
+}
+
+// ItemID returns an identifier for the given item. The GUID is used if
+// the item has one, otherwise the URL of the attachment is used.
+func ItemID(p store.Podcast, item feedparser.Item) string {
+	if ext, ok := p.Extension.Lookup(item.Attachment); ok && len(ext.GUID) > 0 {
+		return ext.GUID
+	}
+
+	return strings.TrimSpace(item.Attachment)
+}
+
+// NewItems returns the items of the given podcast which were published
+// after the latest downloaded episode and match its filter.
+func (c *Client) NewItems(p store.Podcast) ([]feedparser.Item, error) {
+	cast := p.Feed
+	unread, err := c.history.Marker(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.opts.Recent > 0 && len(cast.Items) >= c.opts.Recent {
+		cast.Items = cast.Items[0:c.opts.Recent]
+	}
+
+	var items []feedparser.Item
+	for _, item := range cast.Items {
+		if !item.PubDate.After(unread) {
+			break
+		}
+
+		items = append(items, item)
+	}
+
+	return c.Match(p, items)
+}
+
+// Match returns all of the given items with an attachment matching the
+// filter of the given podcast which haven't been skipped. The
+// attachment of the returned items is replaced with the preferred
+// enclosure, items without an acceptable enclosure are omitted.
+func (c *Client) Match(p store.Podcast, items []feedparser.Item) (matched []feedparser.Item, err error) {
+	f, err := filter.Parse(p.Options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", p.URL, err)
+	}
+
+	pref, err := filter.ParsePreference(p.Options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", p.URL, err)
+	}
+
+	skipped, err := c.history.Skipped(p)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if len(item.Attachment) <= 0 || skipped[ItemID(p, item)] {
+			continue
+		} else if skipped[strings.TrimSpace(item.Attachment)] {
+			continue // Marked as played by an imported subscription list
+		}
+
+		attachment, ok := chooseAttachment(p, pref, item)
+		if !ok {
+			continue
+		}
+
+		item.Attachment = attachment
+		if f.Match(episode(p, item)) {
+			matched = append(matched, item)
+		}
+	}
+
+	return
+}
+
+// chooseAttachment returns the URL of the enclosure of the given item
+// which matches the given preference best. If the enclosures of the
+// item are unknown, its attachment is returned. The boolean return
+// value is false if no enclosure is acceptable, e.g. because all of
+// them exceed the maximum size.
+func chooseAttachment(p store.Podcast, pref *filter.Preference, item feedparser.Item) (string, bool) {
+	ext, ok := p.Extension.Lookup(item.Attachment)
+	if !ok || len(ext.Enclosures) <= 0 {
+		return item.Attachment, true
+	}
+
+	enclosure, ok := pref.Choose(ext.Enclosures)
+	if !ok {
+		return "", false
+	}
+
+	return enclosure.URL, true
+}
+
+// episode returns the information about the given item which is
+// required to match it against the filter of a podcast.
+func episode(p store.Podcast, item feedparser.Item) filter.Episode {
+	e := filter.Episode{
+		Title:     item.Title,
+		Published: item.PubDate,
+	}
+
+	if ext, ok := p.Extension.Lookup(item.Attachment); ok {
+		enclosure, _ := ext.Enclosure(strings.TrimSpace(item.Attachment))
+		e.Description = ext.Description
+		e.Type = enclosure.Type
+		e.Duration = ext.Duration
+	}
+
+	return e
+}
+
+// Downloaded returns true if a file for the given item already exists
+// in the download directory of the given podcast.
+func (c *Client) Downloaded(p store.Podcast, item feedparser.Item) bool {
+	dir, err := c.Dir(p)
+	if err != nil {
+		return false
+	}
+
+	name, err := c.episodeName(p, item)
+	if err != nil {
+		return false
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, name+".*"))
+	return len(matches) > 0
+}
+
+// Download downloads the attachment of the given item to the download
+// directory of the given podcast and records it in the history. The
+// file is named according to the EpisodeName option, if the name can't
+// be determined the name chosen by the downloader is retained.
+// Transcripts and chapters are downloaded if requested. The path of
+// the downloaded file is returned.
+func (c *Client) Download(ctx context.Context, p store.Podcast, item feedparser.Item) (string, error) {
+	c.emit(Event{Type: Started, URL: p.URL, Podcast: p, Item: item})
+	fp, err := c.download(ctx, p, item)
+	c.emit(Event{Type: Downloaded, URL: p.URL, Podcast: p, Item: item, Path: fp, Err: err})
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.history.Record(p, item, fp); err != nil {
+		c.emit(Event{Type: Failed, URL: p.URL, Podcast: p, Item: item, Path: fp, Err: err})
+	}
+
+	if err := c.getExtras(ctx, p, item, fp); err != nil && ctx.Err() == nil {
+		c.emit(Event{Type: Failed, URL: p.URL, Podcast: p, Item: item, Path: fp, Err: err})
+	}
+
+	return fp, nil
+}
+
+func (c *Client) download(ctx context.Context, p store.Podcast, item feedparser.Item) (string, error) {
+	target, err := c.Dir(p)
+	if err != nil {
+		return "", err
+	} else if err := os.MkdirAll(target, 0755); err != nil {
+		return "", err
+	}
+
+	fp, err := c.downloader.Download(ctx, item.Attachment, target)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := c.episodeName(p, item)
+	if err == nil {
+		newfp := filepath.Join(target, name+filepath.Ext(fp))
+		if err = os.Rename(fp, newfp); err != nil {
+			return "", err
+		}
+
+		fp = newfp
+	}
+
+	return fp, nil
+}
+
+// getExtras downloads the transcripts and chapters of the given item
+// if requested. The files are named like the episode file located at
+// the given path but use a different extension.
+func (c *Client) getExtras(ctx context.Context, p store.Podcast, item feedparser.Item, fp string) error {
+	ext, ok := p.Extension.Lookup(item.Attachment)
+	if !ok {
+		return nil
+	}
+
+	base := strings.TrimSuffix(fp, filepath.Ext(fp))
+	if c.opts.Transcripts {
+		for _, t := range ext.Transcripts {
+			path := base + transcriptExt(t)
+			if _, err := os.Stat(path); err == nil {
+				continue // Only download one transcript per format
+			}
+
+			if err := c.getExtra(ctx, t.URL, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.opts.Chapters && len(ext.Chapters) > 0 {
+		path := base + ".chapters.json"
+		if err := c.getExtra(ctx, ext.Chapters, path); err != nil {
+			return err
+		}
+
+		if err := convertChapters(path, base+".chapters.txt"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getExtra downloads the file from the given uri to the given path.
+func (c *Client) getExtra(ctx context.Context, uri, path string) error {
+	fp, err := c.downloader.Download(ctx, uri, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(fp, path)
+}
+
+// convertChapters converts the JSON chapters file located at src to
+// the simple chapter format and writes the result to dest.
+func convertChapters(src, dest string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	parsed, err := extension.ParseChapters(file)
+	if err != nil {
+		return fmt.Errorf("%s: %s", src, err)
+	}
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	if err := extension.WriteChapters(out, parsed); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// transcriptExt returns the file extension used for the transcript.
+func transcriptExt(t extension.Transcript) string {
+	mimetype := strings.ToLower(t.Type)
+	if ext, ok := transcriptExts[mimetype]; ok {
+		return ext
+	}
+
+	if u, err := url.Parse(t.URL); err == nil && len(path.Ext(u.Path)) > 0 {
+		return path.Ext(u.Path)
+	}
+
+	return ".transcript"
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/go-feedparser"
+)
+
+// EventType describes what happened during an update.
+type EventType int
+
+const (
+	// Queued is emitted for each feed before the feeds are fetched.
+	Queued EventType = iota
+
+	// Fetched is emitted after a feed was fetched and identified.
+	// Err is set if fetching the feed failed.
+	Fetched
+
+	// Started is emitted when the download of an episode starts.
+	Started
+
+	// Downloaded is emitted after an episode was downloaded to Path
+	// or its download failed. In the latter case Err is set, the error
+	// is also returned by Download or reported as Failed event.
+	Downloaded
+
+	// Removed is emitted after the episode at Path was removed
+	// according to the retention options.
+	Removed
+
+	// Moved is emitted for feeds which moved permanently. If the Move
+	// option is set, the URL was updated, unless Err is set.
+	Moved
+
+	// Failed is emitted for errors which don't abort the update, e.g.
+	// failed downloads of transcripts.
+	Failed
+
+	// Finished is emitted after all feeds were updated.
+	Finished
+)
+
+// Event is passed to the functions registered using On.
+type Event struct {
+	// Type of the event.
+	Type EventType
+
+	// URL of the feed, empty for Finished events.
+	URL string
+
+	// Podcast the event refers to, the feed is only set for events
+	// emitted after the feed was fetched.
+	Podcast store.Podcast
+
+	// Episode the event refers to, if any.
+	Item feedparser.Item
+
+	// Path of the episode file, if any.
+	Path string
+
+	// Number of downloaded episodes, only set for Finished events.
+	Downloaded int
+
+	// Error which occurred, if any.
+	Err error
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"github.com/nmeum/cpod/store"
+	"os"
+	"path/filepath"
+)
+
+// Feeds manages the subscribed feeds.
+type Feeds interface {
+	// Load returns the subscribed feeds.
+	Load() (*store.Store, error)
+
+	// Modify passes the subscribed feeds to the given function and
+	// stores the modified feeds afterwards, unless it failed.
+	Modify(fn func(*store.Store) error) error
+}
+
+// FileFeeds stores the subscribed feeds in a URL file. It doesn't
+// prevent concurrent modifications by other processes.
+type FileFeeds struct {
+	// Path of the URL file, it is created if it doesn't exist.
+	Path string
+}
+
+// Load loads the URL file. A missing URL file results in an empty
+// store.
+func (f FileFeeds) Load() (*store.Store, error) {
+	storage, err := store.Load(f.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return storage, nil
+}
+
+// Modify loads the URL file, passes it to the given function and saves
+// it afterwards.
+func (f FileFeeds) Modify(fn func(*store.Store) error) error {
+	storage, err := f.Load()
+	if err != nil {
+		return err
+	} else if err := fn(storage); err != nil {
+		return err
+	} else if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+
+	return storage.Save()
+}
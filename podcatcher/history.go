@@ -0,0 +1,252 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/go-feedparser"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// History stores the download history of podcasts.
+type History interface {
+	// Marker returns the publication date of the latest downloaded
+	// episode of the given podcast, the zero time if there is none.
+	Marker(p store.Podcast) (time.Time, error)
+
+	// SetMarker replaces the publication date of the latest
+	// downloaded episode of the given podcast.
+	SetMarker(p store.Podcast, latest time.Time) error
+
+	// Skipped returns the identifiers of the skipped episodes of the
+	// given podcast, see ItemID.
+	Skipped(p store.Podcast) (map[string]bool, error)
+
+	// SetSkipped replaces the identifiers of the skipped episodes of
+	// the given podcast.
+	SetSkipped(p store.Podcast, skipped map[string]bool) error
+
+	// Record records that the given item of the given podcast was
+	// downloaded to the given path.
+	Record(p store.Podcast, item feedparser.Item, path string) error
+
+	// Episodes returns the title of the given podcast and its recorded
+	// episodes keyed by file name. If nothing was recorded yet, an
+	// empty title and map are returned.
+	Episodes(p store.Podcast) (string, map[string]Episode, error)
+
+	// Name returns the name of the download directory of the given
+	// podcast recorded by SetName, empty if none was recorded.
+	Name(p store.Podcast) (string, error)
+
+	// SetName records the name of the download directory of the given
+	// podcast.
+	SetName(p store.Podcast, name string) error
+}
+
+// Episode describes a downloaded episode.
+type Episode struct {
+	// Title of the episode.
+	Title string
+
+	// Time the episode was published.
+	Published time.Time
+
+	// URL the episode was downloaded from.
+	URL string
+}
+
+// DirHistory stores the history of each podcast in a directory named
+// after its identifier.
+type DirHistory struct {
+	// Directory containing the directories of all podcasts.
+	Dir string
+}
+
+// file returns the path of the file with the given name in the
+// directory of the given podcast.
+func (h DirHistory) file(p store.Podcast, name string) string {
+	return filepath.Join(p.Dir(h.Dir), name)
+}
+
+// write replaces the file with the given name in the directory of the
+// given podcast with the given data.
+func (h DirHistory) write(p store.Podcast, name string, data []byte) error {
+	path := h.file(p, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Marker implements the History interface.
+func (h DirHistory) Marker(p store.Podcast) (marker time.Time, err error) {
+	file, err := os.Open(h.file(p, ".latest"))
+	if os.IsNotExist(err) {
+		return marker, nil
+	} else if err != nil {
+		return
+	}
+
+	defer file.Close()
+	var timestamp int64
+
+	if _, err = fmt.Fscanf(file, "%d\n", &timestamp); err != nil {
+		return
+	}
+
+	marker = time.Unix(timestamp, 0)
+	return
+}
+
+// SetMarker implements the History interface.
+func (h DirHistory) SetMarker(p store.Podcast, latest time.Time) error {
+	return h.write(p, ".latest", []byte(fmt.Sprintf("%d\n", latest.Unix())))
+}
+
+// Skipped implements the History interface.
+func (h DirHistory) Skipped(p store.Podcast) (skipped map[string]bool, err error) {
+	skipped = make(map[string]bool)
+	file, err := os.Open(h.file(p, ".skipped"))
+	if os.IsNotExist(err) {
+		return skipped, nil
+	} else if err != nil {
+		return
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if id := strings.TrimSpace(scanner.Text()); len(id) > 0 {
+			skipped[id] = true
+		}
+	}
+
+	err = scanner.Err()
+	return
+}
+
+// SetSkipped implements the History interface.
+func (h DirHistory) SetSkipped(p store.Podcast, skipped map[string]bool) error {
+	var ids []string
+	for id := range skipped {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var data strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&data, "%s\n", id)
+	}
+
+	return h.write(p, ".skipped", []byte(data.String()))
+}
+
+// Record implements the History interface. The title, publication date
+// and URL of the item are appended to the episode log of the podcast,
+// the title of the podcast is recorded as well.
+func (h DirHistory) Record(p store.Podcast, item feedparser.Item, path string) error {
+	title := strings.Join(strings.Fields(p.Feed.Title), " ")
+	if err := h.write(p, ".title", []byte(title+"\n")); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(h.file(p, ".episodes"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+	title = strings.Join(strings.Fields(item.Title), " ")
+	url := strings.TrimSpace(item.Attachment)
+	if _, err := fmt.Fprintf(file, "%s\t%d\t%s\t%s\n", filepath.Base(path), item.PubDate.Unix(), title, url); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Episodes implements the History interface.
+func (h DirHistory) Episodes(p store.Podcast) (string, map[string]Episode, error) {
+	return ReadEpisodes(p.Dir(h.Dir))
+}
+
+// Name implements the History interface.
+func (h DirHistory) Name(p store.Podcast) (string, error) {
+	data, err := ioutil.ReadFile(h.file(p, "name"))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SetName implements the History interface.
+func (h DirHistory) SetName(p store.Podcast, name string) error {
+	return h.write(p, "name", []byte(name+"\n"))
+}
+
+// ReadEpisodes returns the podcast title and the episode log recorded
+// by DirHistory in the given directory.
+func ReadEpisodes(dir string) (title string, episodes map[string]Episode, err error) {
+	episodes = make(map[string]Episode)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".title"))
+	if err == nil {
+		title = strings.TrimSpace(string(data))
+	} else if !os.IsNotExist(err) {
+		return
+	}
+
+	file, err := os.Open(filepath.Join(dir, ".episodes"))
+	if os.IsNotExist(err) {
+		return title, episodes, nil
+	} else if err != nil {
+		return
+	}
+
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) < 4 {
+			continue
+		}
+
+		timestamp, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		episodes[fields[0]] = Episode{fields[2], time.Unix(timestamp, 0), fields[3]}
+	}
+
+	err = scanner.Err()
+	return
+}
@@ -0,0 +1,155 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"fmt"
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/cpod/util"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir returns the download directory of the given podcast. It is named
+// after the name option or the escaped title of the feed if the option
+// is not set.
+func (c *Client) Dir(p store.Podcast) (string, error) {
+	name := p.Options["name"]
+	if len(name) <= 0 {
+		var err error
+		if name, err = util.Escape(p.Feed.Title); err != nil {
+			return "", err
+		}
+	} else if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("%s: invalid name option %q", p.URL, name)
+	}
+
+	return filepath.Join(c.opts.DownloadDir, name), nil
+}
+
+// Identify stores a stable identifier and the name of the download
+// directory as options of the given podcast, unless it has both
+// already. Podcasts which aren't subscribed are identified temporarily.
+// If the name option changed since the podcast was identified last, the
+// download directory is renamed accordingly.
+func (c *Client) Identify(p *store.Podcast) error {
+	if len(p.Options["id"]) <= 0 || len(p.Options["name"]) <= 0 {
+		if err := c.assignID(p); err != nil {
+			return err
+		}
+	}
+
+	if c.opts.Identified != nil {
+		if err := c.opts.Identified(*p); err != nil {
+			return err
+		}
+	}
+
+	dir, err := c.Dir(*p)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(dir)
+	old, err := c.history.Name(*p)
+	if err != nil {
+		return err
+	} else if len(old) > 0 && old != name {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			if err := os.Rename(filepath.Join(c.opts.DownloadDir, old), dir); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	if old == name {
+		return nil
+	}
+
+	return c.history.SetName(*p, name)
+}
+
+// assignID determines the identifier and directory name of the given
+// podcast and stores them in its options. An existing directory named
+// after the escaped title of the feed, which was used by previous
+// versions, is retained unless another podcast uses it already.
+func (c *Client) assignID(p *store.Podcast) error {
+	opts := make(store.Options)
+	for k, v := range p.Options {
+		opts[k] = v
+	}
+
+	id := p.ID()
+	opts["id"] = id
+
+	legacy, err := util.Escape(p.Feed.Title)
+	if err != nil {
+		legacy = id
+	}
+
+	migrate := false
+	if _, err := os.Stat(filepath.Join(c.opts.DownloadDir, legacy)); err == nil {
+		migrate = true
+	}
+
+	fresh := legacy
+	if n, err := c.dirName(*p); err == nil {
+		fresh = n
+	}
+
+	err = c.feeds.Modify(func(storage *store.Store) error {
+		if !storage.Contains(p.URL) {
+			return nil // Only identify the podcast temporarily
+		}
+
+		if len(opts["name"]) <= 0 {
+			// The existing directory might belong to another podcast
+			// with the same title, it is only taken over otherwise.
+			opts["name"] = legacy
+			if !migrate || nameTaken(storage, p.URL, legacy) {
+				opts["name"] = fresh
+			}
+
+			if name := opts["name"]; nameTaken(storage, p.URL, name) {
+				// The id might be short, use a hash of the URL instead.
+				hash := store.Podcast{URL: p.URL}.ID()
+				opts["name"] = name + "-" + hash[0:8]
+			}
+		}
+
+		storage.SetOptions(p.URL, opts)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.Options = opts
+	return nil
+}
+
+// nameTaken returns true if a podcast other than the one with the given
+// URL uses the given directory name.
+func nameTaken(storage *store.Store, url, name string) bool {
+	for _, u := range storage.URLs() {
+		if u != url && storage.Options(u)["name"] == name {
+			return true
+		}
+	}
+
+	return false
+}
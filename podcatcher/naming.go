@@ -0,0 +1,99 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"fmt"
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/cpod/util"
+	"github.com/nmeum/go-feedparser"
+	"path/filepath"
+	"strings"
+)
+
+// dirName returns the name of the download directory of the given
+// podcast according to the DirectoryName option.
+func (c *Client) dirName(p store.Podcast) (string, error) {
+	return ExpandName(c.opts.DirectoryName, map[string]string{
+		"title": p.Feed.Title,
+		"id":    p.ID(),
+	})
+}
+
+// episodeName returns the file name, without extension, of the given
+// item according to the EpisodeName option.
+func (c *Client) episodeName(p store.Podcast, item feedparser.Item) (string, error) {
+	return ExpandName(c.opts.EpisodeName, map[string]string{
+		"title":   item.Title,
+		"podcast": p.Feed.Title,
+		"date":    item.PubDate.Format("2006-01-02"),
+	})
+}
+
+// ExpandName replaces the {placeholders} in the given template with
+// the escaped values of the given variables. An error is returned if
+// the template contains an unknown placeholder, a value can't be
+// escaped or the result isn't a valid file name.
+func ExpandName(template string, vars map[string]string) (string, error) {
+	var name strings.Builder
+	for len(template) > 0 {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			name.WriteString(template)
+			break
+		}
+
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in %q", template)
+		}
+
+		key := template[start+1 : start+end]
+		value, ok := vars[key]
+		if !ok {
+			return "", fmt.Errorf("unknown placeholder {%s}", key)
+		}
+
+		escaped, err := util.Escape(value)
+		if err != nil {
+			return "", err
+		}
+
+		name.WriteString(template[0:start])
+		name.WriteString(escaped)
+		template = template[start+end+1:]
+	}
+
+	s := strings.TrimSpace(name.String())
+	if len(s) <= 0 || s != filepath.Base(s) || strings.HasPrefix(s, ".") {
+		return "", fmt.Errorf("invalid file name %q", s)
+	}
+
+	return s, nil
+}
+
+// CheckNames returns an error if the DirectoryName or EpisodeName
+// option of the client contains an invalid template.
+func (c *Client) CheckNames() error {
+	example := store.Podcast{Feed: feedparser.Feed{Title: "title"}}
+	if _, err := c.dirName(example); err != nil {
+		return fmt.Errorf("directory name: %s", err)
+	} else if _, err := c.episodeName(example, feedparser.Item{Title: "title"}); err != nil {
+		return fmt.Errorf("episode name: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package podcatcher implements the update logic of cpod, i.e. fetching
+// subscribed feeds and downloading their new episodes, for embedding
+// it into other programs.
+package podcatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nmeum/cpod/filter"
+	"github.com/nmeum/cpod/store"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSubscribed is returned when subscribing to a feed twice.
+	ErrSubscribed = errors.New("already subscribed")
+
+	// ErrNotSubscribed is returned for feeds which aren't subscribed.
+	ErrNotSubscribed = errors.New("not subscribed")
+
+	// ErrInvalidURL is returned for feed URLs which aren't HTTP URLs.
+	ErrInvalidURL = errors.New("invalid feed URL")
+
+	// ErrInvalidOptions is returned for invalid filter options.
+	ErrInvalidOptions = errors.New("invalid options")
+)
+
+// Options configure a Client.
+type Options struct {
+	// Directory episodes are downloaded to, each podcast is stored in
+	// a subdirectory.
+	DownloadDir string
+
+	// Number of maximal parallel downloads, zero means no limit.
+	Concurrency int
+
+	// Number of most recent episodes of a feed which are considered
+	// for download, zero means all.
+	Recent int
+
+	// Whether chapters of episodes are downloaded.
+	Chapters bool
+
+	// Whether transcripts of episodes are downloaded.
+	Transcripts bool
+
+	// Whether the URLs of permanently moved feeds are updated.
+	Move bool
+
+	// Template for the name of the download directory of a feed, see
+	// ExpandName. The placeholders {title} and {id} are supported.
+	// Defaults to "{title}".
+	DirectoryName string
+
+	// Template for the file name of an episode without extension, see
+	// ExpandName. The placeholders {title}, {podcast} and {date} are
+	// supported. Defaults to "{title}".
+	EpisodeName string
+
+	// Number of most recently downloaded episodes kept per podcast,
+	// zero means all.
+	Keep int
+
+	// Maximal time since the download of an episode after which it is
+	// removed, zero means episodes are kept forever.
+	MaxAge time.Duration
+
+	// Function called after a podcast was identified and before its
+	// history is accessed, e.g. to migrate state. Optional.
+	Identified func(p store.Podcast) error
+}
+
+// Client fetches subscribed feeds and downloads their new episodes. It
+// is safe for concurrent use, but concurrent updates of the same feeds
+// should be avoided.
+type Client struct {
+	opts       Options
+	feeds      Feeds
+	downloader Downloader
+	history    History
+
+	mu       sync.Mutex
+	handlers []func(Event)
+}
+
+// New returns a new client using the given options, subscribed feeds,
+// downloader and history.
+func New(opts Options, feeds Feeds, downloader Downloader, history History) *Client {
+	if len(opts.DirectoryName) <= 0 {
+		opts.DirectoryName = "{title}"
+	}
+	if len(opts.EpisodeName) <= 0 {
+		opts.EpisodeName = "{title}"
+	}
+
+	return &Client{opts: opts, feeds: feeds, downloader: downloader, history: history}
+}
+
+// Options returns the options of the client.
+func (c *Client) Options() Options {
+	return c.opts
+}
+
+// History returns the history of the client.
+func (c *Client) History() History {
+	return c.history
+}
+
+// On registers a function which is called for every event. Functions
+// may be called concurrently from multiple goroutines.
+func (c *Client) On(fn func(Event)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, fn)
+}
+
+// emit passes the given event to all registered functions.
+func (c *Client) emit(e Event) {
+	c.mu.Lock()
+	handlers := c.handlers
+	c.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(e)
+	}
+}
+
+// Validate returns ErrInvalidURL if the given feed URL isn't an HTTP
+// URL and ErrInvalidOptions if the given feed options contain an
+// invalid filter or preference, which would prevent updating the feed.
+func Validate(feedURL string, opts store.Options) error {
+	u, err := url.Parse(feedURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) <= 0 {
+		return fmt.Errorf("%w %q", ErrInvalidURL, feedURL)
+	}
+
+	if _, err := filter.Parse(opts); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, err)
+	} else if _, err := filter.ParsePreference(opts); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, err)
+	}
+
+	return nil
+}
+
+// Subscribe subscribes to the feed at the given URL using the given
+// feed options, e.g. filters. The URL and options are checked using
+// Validate.
+func (c *Client) Subscribe(feedURL string, opts store.Options) error {
+	if err := Validate(feedURL, opts); err != nil {
+		return err
+	}
+
+	return c.feeds.Modify(func(storage *store.Store) error {
+		if storage.Contains(feedURL) {
+			return fmt.Errorf("%q is %w", feedURL, ErrSubscribed)
+		}
+
+		storage.Add(feedURL)
+		if len(opts) > 0 {
+			storage.SetOptions(feedURL, opts)
+		}
+
+		return nil
+	})
+}
+
+// Unsubscribe removes the subscription of the feed at the given URL.
+// Downloaded episodes are retained.
+func (c *Client) Unsubscribe(feedURL string) error {
+	return c.feeds.Modify(func(storage *store.Store) error {
+		if !storage.Remove(feedURL) {
+			return fmt.Errorf("%q is %w", feedURL, ErrNotSubscribed)
+		}
+
+		return nil
+	})
+}
+
+// Update downloads new episodes of all subscribed feeds. It returns all
+// podcasts which were fetched successfully.
+func (c *Client) Update(ctx context.Context) ([]store.Podcast, error) {
+	storage, err := c.feeds.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.UpdateStore(ctx, storage), nil
+}
+
+// UpdateStore downloads new episodes of all feeds in the given store,
+// which may be a subset of the subscribed feeds. It returns all
+// podcasts which were fetched successfully. Errors are reported as
+// events. Feeds which no longer exist are marked using the gone option
+// and aren't fetched anymore.
+func (c *Client) UpdateStore(ctx context.Context, storage *store.Store) (fetched []store.Podcast) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var running, downloaded int
+
+	var urls []string
+	for _, url := range storage.URLs() {
+		if len(storage.Options(url)["gone"]) <= 0 {
+			urls = append(urls, url)
+		}
+	}
+
+	storage = storage.Subset(urls)
+	for _, url := range urls {
+		c.emit(Event{Type: Queued, URL: url})
+	}
+
+	for cast := range storage.Fetch(ctx) {
+		if cast.Error == nil {
+			if err := c.Identify(&cast); err != nil {
+				cast.Error = err
+			} else {
+				fetched = append(fetched, cast)
+			}
+		} else if errors.Is(cast.Error, store.ErrGone) {
+			if err := c.markGone(cast.URL); err != nil {
+				c.emit(Event{Type: Failed, URL: cast.URL, Podcast: cast, Err: err})
+			}
+		}
+
+		c.emit(Event{Type: Fetched, URL: cast.URL, Podcast: cast, Err: cast.Error})
+		if cast.Error != nil {
+			continue
+		}
+
+		mu.Lock()
+		running++
+		mu.Unlock()
+
+		wg.Add(1)
+		go func(p store.Podcast) {
+			defer func() {
+				mu.Lock()
+				running--
+				mu.Unlock()
+				wg.Done()
+			}()
+
+			n, err := c.updatePodcast(ctx, p)
+			if err != nil && ctx.Err() == nil {
+				c.emit(Event{Type: Failed, URL: p.URL, Podcast: p, Err: err})
+			}
+
+			mu.Lock()
+			downloaded += n
+			mu.Unlock()
+		}(cast)
+
+		for c.opts.Concurrency > 0 && ctx.Err() == nil {
+			mu.Lock()
+			n := running
+			mu.Unlock()
+
+			if n < c.opts.Concurrency {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	wg.Wait()
+	c.relocate(fetched)
+	c.emit(Event{Type: Finished, Downloaded: downloaded})
+
+	return
+}
+
+// updatePodcast downloads the new episodes of the given podcast and
+// removes old ones according to the retention options afterwards. It
+// returns the number of downloaded episodes.
+func (c *Client) updatePodcast(ctx context.Context, p store.Podcast) (int, error) {
+	items, err := c.NewItems(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for i := len(items) - 1; i >= 0 && ctx.Err() == nil; i-- {
+		item := items[i]
+		if _, err := c.Download(ctx, p, item); err != nil {
+			if ctx.Err() != nil {
+				return n, nil
+			}
+
+			return n, err
+		}
+
+		// Record the download before checking for cancellation,
+		// otherwise it would be repeated by the next update.
+		n++
+		if err := c.history.SetMarker(p, item.PubDate); err != nil {
+			return n, err
+		}
+	}
+
+	return n, c.Prune(p)
+}
+
+// markGone stores the current date in the gone option of the feed with
+// the given URL, it is skipped by later updates.
+func (c *Client) markGone(url string) error {
+	return c.feeds.Modify(func(storage *store.Store) error {
+		if !storage.Contains(url) {
+			return nil
+		}
+
+		opts := make(store.Options)
+		for k, v := range storage.Options(url) {
+			opts[k] = v
+		}
+
+		opts["gone"] = time.Now().Format("2006-01-02")
+		storage.SetOptions(url, opts)
+		return nil
+	})
+}
+
+// relocate updates the URLs of the given podcasts which moved
+// permanently if the Move option is set. An event is emitted for each
+// moved podcast.
+func (c *Client) relocate(fetched []store.Podcast) {
+	for _, p := range fetched {
+		if len(p.Moved) <= 0 {
+			continue
+		}
+
+		var err error
+		if c.opts.Move {
+			err = c.feeds.Modify(func(storage *store.Store) error {
+				if !storage.Move(p.URL, p.Moved) {
+					return fmt.Errorf("%q is %w", p.URL, ErrNotSubscribed)
+				}
+
+				return nil
+			})
+		}
+
+		c.emit(Event{Type: Moved, URL: p.URL, Podcast: p, Err: err})
+	}
+}
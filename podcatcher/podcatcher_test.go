@@ -0,0 +1,375 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"context"
+	"errors"
+	"github.com/nmeum/cpod/store"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// newClient returns a client using a temporary directory and a test
+// server which serves testdata/testUpdate.rss and its episodes.
+func newClient(t *testing.T) (*Client, string, func()) {
+	tmpl, err := template.ParseFiles(filepath.Join("testdata", "testUpdate.rss"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/feed.rss" {
+			w.Header().Set("Content-Type", "application/rss+xml")
+			tmpl.Execute(w, ts.URL)
+			return
+		}
+
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("audio"))
+	}))
+
+	dir, err := ioutil.TempDir("", "podcatcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(Options{DownloadDir: filepath.Join(dir, "podcasts")},
+		FileFeeds{filepath.Join(dir, "urls")}, HTTPDownloader{}, DirHistory{filepath.Join(dir, "state")})
+
+	return c, ts.URL + "/feed.rss", func() {
+		ts.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c, feedURL, cleanup := newClient(t)
+	defer cleanup()
+
+	var mu sync.Mutex
+	events := make(map[EventType]int)
+	c.On(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if e.Err != nil {
+			t.Error(e.Err)
+		}
+		events[e.Type]++
+	})
+
+	if err := c.Subscribe(feedURL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, err := c.Update(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(fetched) != 1 {
+		t.Fatalf("Expected %d - got %d", 1, len(fetched))
+	}
+
+	p := fetched[0]
+	if name := p.Options["name"]; name != "Test-Cast" {
+		t.Fatalf("Expected %q - got %q", "Test-Cast", name)
+	}
+
+	for _, name := range []string{"First-Episode.mp3", "Second-Episode.mp3"} {
+		data, err := ioutil.ReadFile(filepath.Join(c.Options().DownloadDir, "Test-Cast", name))
+		if err != nil {
+			t.Fatal(err)
+		} else if string(data) != "audio" {
+			t.Fatalf("Expected %q - got %q", "audio", string(data))
+		}
+	}
+
+	expected := map[EventType]int{Queued: 1, Fetched: 1, Started: 2, Downloaded: 2, Finished: 1}
+	for typ, n := range expected {
+		if events[typ] != n {
+			t.Fatalf("Expected %d - got %d", n, events[typ])
+		}
+	}
+
+	marker, err := c.History().Marker(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest := time.Date(2015, 6, 4, 10, 0, 0, 0, time.UTC)
+	if !marker.Equal(latest) {
+		t.Fatalf("Expected %q - got %q", latest, marker)
+	}
+
+	title, episodes, err := c.History().Episodes(p)
+	if err != nil {
+		t.Fatal(err)
+	} else if title != "Test Cast" {
+		t.Fatalf("Expected %q - got %q", "Test Cast", title)
+	} else if e := episodes["First-Episode.mp3"]; e.Title != "First Episode" {
+		t.Fatalf("Expected %q - got %q", "First Episode", e.Title)
+	}
+
+	// Already downloaded episodes must not be downloaded again.
+	events = make(map[EventType]int)
+	if _, err := c.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if events[Started] != 0 {
+		t.Fatalf("Expected %d - got %d", 0, events[Started])
+	}
+}
+
+// cancelDownloader cancels the update after the first download.
+type cancelDownloader struct {
+	Downloader
+	cancel context.CancelFunc
+}
+
+func (d cancelDownloader) Download(ctx context.Context, uri, dir string) (string, error) {
+	defer d.cancel()
+	return d.Downloader.Download(ctx, uri, dir)
+}
+
+func TestUpdateCanceled(t *testing.T) {
+	c, feedURL, cleanup := newClient(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.downloader = cancelDownloader{c.downloader, cancel}
+
+	if err := c.Subscribe(feedURL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, err := c.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(fetched) != 1 {
+		t.Fatalf("Expected %d - got %d", 1, len(fetched))
+	}
+
+	marker, err := c.History().Marker(fetched[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := time.Date(2015, 6, 3, 10, 0, 0, 0, time.UTC)
+	if !marker.Equal(first) {
+		t.Fatalf("Expected %q - got %q", first, marker)
+	}
+}
+
+func TestUpdateMaxSize(t *testing.T) {
+	c, feedURL, cleanup := newClient(t)
+	defer cleanup()
+
+	if err := c.Subscribe(feedURL, store.Options{"max-size": "4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched, err := c.Update(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	} else if len(fetched) != 1 {
+		t.Fatalf("Expected %d - got %d", 1, len(fetched))
+	}
+
+	if c.Downloaded(fetched[0], fetched[0].Feed.Items[0]) {
+		t.Fatal("Episode exceeding the maximum size was downloaded")
+	}
+}
+
+func TestUpdateSkipped(t *testing.T) {
+	c, feedURL, cleanup := newClient(t)
+	defer cleanup()
+
+	if err := c.Subscribe(feedURL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	p := store.Podcast{URL: feedURL, Options: store.Options{"id": "skipped", "name": "skipped"}}
+	if err := c.Unsubscribe(feedURL); err != nil {
+		t.Fatal(err)
+	} else if err := c.Subscribe(feedURL, p.Options); err != nil {
+		t.Fatal(err)
+	}
+
+	skipped := map[string]bool{strings.TrimSuffix(feedURL, "feed.rss") + "1.mp3": true}
+	if err := c.History().SetSkipped(p, skipped); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(c.Options().DownloadDir, "skipped"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) != 1 || files[0].Name() != "Second-Episode.mp3" {
+		t.Fatalf("Expected %q - got %v", "Second-Episode.mp3", files)
+	}
+}
+
+func TestUpdateGone(t *testing.T) {
+	c, _, cleanup := newClient(t)
+	defer cleanup()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer ts.Close()
+
+	var failed []error
+	c.On(func(e Event) {
+		if e.Type == Fetched && e.Err != nil {
+			failed = append(failed, e.Err)
+		}
+	})
+
+	if err := c.Subscribe(ts.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Update(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("Expected %d - got %d", 1, requests)
+	} else if len(failed) != 1 || !errors.Is(failed[0], store.ErrGone) {
+		t.Fatalf("Expected %q - got %v", store.ErrGone, failed)
+	}
+
+	storage, err := c.feeds.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if gone := storage.Options(ts.URL)["gone"]; gone != today {
+		t.Fatalf("Expected %q - got %q", today, gone)
+	}
+}
+
+func TestIdentifyNameTaken(t *testing.T) {
+	c, feedURL, cleanup := newClient(t)
+	defer cleanup()
+
+	otherURL := strings.TrimSuffix(feedURL, "feed.rss") + "other.rss"
+	if err := c.Subscribe(feedURL, store.Options{"id": "a"}); err != nil {
+		t.Fatal(err)
+	} else if err := c.Subscribe(otherURL, store.Options{"id": "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]bool)
+	for i, u := range []string{feedURL, otherURL} {
+		p := store.Podcast{URL: u, Options: store.Options{"id": []string{"a", "b"}[i]}}
+		p.Feed.Title = "Test Cast"
+		if err := c.Identify(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		// The directory of the first podcast must not be mistaken
+		// for one created by a previous version.
+		dir, err := c.Dir(p)
+		if err != nil {
+			t.Fatal(err)
+		} else if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		names[p.Options["name"]] = true
+	}
+
+	if len(names) != 2 || !names["Test-Cast"] {
+		t.Fatalf("Expected distinct names - got %v", names)
+	}
+}
+
+func TestIdentifyLegacyDir(t *testing.T) {
+	c, feedURL, cleanup := newClient(t)
+	defer cleanup()
+
+	c.opts.DirectoryName = "{title}-{id}"
+	if err := os.MkdirAll(filepath.Join(c.Options().DownloadDir, "Test-Cast"), 0755); err != nil {
+		t.Fatal(err)
+	} else if err := c.Subscribe(feedURL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	p := store.Podcast{URL: feedURL, Options: store.Options{}}
+	p.Feed.Title = "Test Cast"
+	if err := c.Identify(&p); err != nil {
+		t.Fatal(err)
+	} else if name := p.Options["name"]; name != "Test-Cast" {
+		t.Fatalf("Expected %q - got %q", "Test-Cast", name)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c, feedURL, cleanup := newClient(t)
+	defer cleanup()
+
+	if err := c.Subscribe("ftp://example.com/feed.rss", nil); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("Expected %q - got %q", ErrInvalidURL, err)
+	} else if err := c.Subscribe(feedURL, store.Options{"max-size": "huge"}); !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("Expected %q - got %q", ErrInvalidOptions, err)
+	}
+
+	if err := c.Subscribe(feedURL, nil); err != nil {
+		t.Fatal(err)
+	} else if err := c.Subscribe(feedURL, nil); !errors.Is(err, ErrSubscribed) {
+		t.Fatalf("Expected %q - got %q", ErrSubscribed, err)
+	}
+
+	if err := c.Unsubscribe(feedURL); err != nil {
+		t.Fatal(err)
+	} else if err := c.Unsubscribe(feedURL); !errors.Is(err, ErrNotSubscribed) {
+		t.Fatalf("Expected %q - got %q", ErrNotSubscribed, err)
+	}
+}
+
+func TestExpandName(t *testing.T) {
+	vars := map[string]string{"title": "Hello World", "date": "2015-06-04"}
+
+	name, err := ExpandName("{date} {title}", vars)
+	if err != nil {
+		t.Fatal(err)
+	} else if name != "2015-06-04 Hello-World" {
+		t.Fatalf("Expected %q - got %q", "2015-06-04 Hello-World", name)
+	}
+
+	for _, tmpl := range []string{"{unknown}", "{title", ".{title}", ""} {
+		if _, err := ExpandName(tmpl, vars); err == nil {
+			t.Fatalf("Expected error for %q", tmpl)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package podcatcher
+
+import (
+	"github.com/nmeum/cpod/store"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Media types of common podcast file extensions, not all of them are
+// known to the mime package on every system.
+var mediaTypes = map[string]string{
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".m4b":  "audio/mp4",
+	".m4v":  "video/x-m4v",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".mp4":  "video/mp4",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".webm": "video/webm",
+}
+
+// MediaType returns the MIME type of the audio or video file with the
+// given name. If the file isn't an audio or video file, false is
+// returned.
+func MediaType(name string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if t, ok := mediaTypes[ext]; ok {
+		return t, true
+	}
+
+	t, _, err := mime.ParseMediaType(mime.TypeByExtension(ext))
+	if err != nil || (!strings.HasPrefix(t, "audio/") && !strings.HasPrefix(t, "video/")) {
+		return "", false
+	}
+
+	return t, true
+}
+
+// Prune removes old episodes of the given podcast from its download
+// directory according to the Keep and MaxAge options. Episodes are
+// ordered by the time they were downloaded, transcripts and chapters of
+// removed episodes are removed as well.
+func (c *Client) Prune(p store.Podcast) error {
+	keep, maxAge := c.opts.Keep, c.opts.MaxAge
+	if keep <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	dir, err := c.Dir(p)
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var episodes []os.FileInfo
+	for _, f := range files {
+		if _, ok := MediaType(f.Name()); ok && !f.IsDir() && !strings.HasPrefix(f.Name(), ".") {
+			episodes = append(episodes, f)
+		}
+	}
+
+	sort.Slice(episodes, func(i, j int) bool {
+		return episodes[i].ModTime().After(episodes[j].ModTime())
+	})
+
+	for i, e := range episodes {
+		if (keep <= 0 || i < keep) && (maxAge <= 0 || time.Since(e.ModTime()) <= maxAge) {
+			continue
+		}
+
+		stem := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())) + "."
+		for _, f := range files {
+			name := f.Name()
+			if _, media := MediaType(name); name != e.Name() && (f.IsDir() || media || !strings.HasPrefix(name, stem)) {
+				continue // Neither the episode nor one of its extras
+			}
+
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+
+		c.emit(Event{Type: Removed, URL: p.URL, Podcast: p, Path: filepath.Join(dir, e.Name())})
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/store"
+	"github.com/nmeum/cpod/websub"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Timeout of requests sent to WebSub hubs.
+const hubTimeout = 30 * time.Second
+
+// pusher manages the WebSub subscriptions of the daemon. It is safe
+// for concurrent use. All methods of a nil pusher are no-ops.
+type pusher struct {
+	sub *websub.Subscriber
+
+	// Feed URLs of received notifications.
+	pushed chan string
+
+	mu    sync.Mutex
+	feeds map[string]string // Topic to feed URL
+}
+
+// newPusher returns a new pusher whose callbacks are located below the
+// given URL.
+func newPusher(callback string) *pusher {
+	p := &pusher{
+		pushed: make(chan string, 64),
+		feeds:  make(map[string]string),
+	}
+
+	p.sub = websub.New(callback, p.notify)
+	return p
+}
+
+// notify queues the feed of the given topic for an update.
+func (p *pusher) notify(topic string) {
+	p.mu.Lock()
+	feedURL, ok := p.feeds[topic]
+	p.mu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	select {
+	case p.pushed <- feedURL:
+	default:
+		app.Logger.Printf("dropped notification for %q\n", feedURL)
+	}
+}
+
+// subscribe subscribes to the hub advertised by the given podcast, if
+// any. Subscriptions which don't expire within the given duration are
+// not renewed.
+func (p *pusher) subscribe(ctx context.Context, cast store.Podcast, margin time.Duration) error {
+	if p == nil {
+		return nil
+	}
+
+	hub := cast.Extension.Link("hub")
+	if len(hub) <= 0 {
+		return nil
+	}
+
+	base, err := url.Parse(cast.URL)
+	if err != nil {
+		return err
+	}
+
+	ref, err := url.Parse(hub)
+	if err != nil {
+		return err
+	}
+
+	topic := cast.Extension.Link("self")
+	if len(topic) <= 0 {
+		topic = cast.URL
+	}
+
+	p.mu.Lock()
+	p.feeds[topic] = cast.URL
+	p.mu.Unlock()
+
+	if !p.sub.Due(topic, margin) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hubTimeout)
+	defer cancel()
+
+	return p.sub.Subscribe(ctx, base.ResolveReference(ref).String(), topic)
+}
+
+// prune unsubscribes from the topics of all feeds which are not part
+// of the given set of feed URLs.
+func (p *pusher) prune(ctx context.Context, subscribed map[string]bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	var topics []string
+	for topic, feedURL := range p.feeds {
+		if !subscribed[feedURL] {
+			topics = append(topics, topic)
+			delete(p.feeds, topic)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, topic := range topics {
+		ctx, cancel := context.WithTimeout(ctx, hubTimeout)
+		if err := p.sub.Unsubscribe(ctx, topic); err != nil {
+			app.Logger.Println(err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/podcatcher"
+	"github.com/nmeum/cpod/rss"
+	"github.com/nmeum/cpod/store"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the generated feed files.
+const feedFile = "feed.rss"
+
+// feed writes RSS feeds of the downloaded episodes to the download
+// directory, one per podcast and a combined one.
+func feed(ctx context.Context, storage *store.Store, args []string) error {
+	flags := flag.NewFlagSet("feed", flag.ContinueOnError)
+	base := flags.String("b", "", "base URL of the download directory")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: %s feed [-b URL]\n", app.Name)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("too many arguments")
+	}
+
+	return writeFeeds(*base)
+}
+
+// writeFeeds writes the RSS feed of each podcast to its directory and
+// the combined feed of all podcasts to the download directory. The
+// enclosure URLs are relative to the given base URL.
+func writeFeeds(base string) error {
+	u, err := baseURL(base)
+	if err != nil {
+		return err
+	}
+
+	dirs, err := podcastDirs()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		r, err := buildFeed(u, false, dir)
+		if err != nil {
+			return err
+		}
+
+		if err := r.Save(filepath.Join(app.DownloadDir, dir, feedFile)); err != nil {
+			return err
+		}
+	}
+
+	r, err := buildFeed(u, true, dirs...)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(app.DownloadDir, 0755); err != nil {
+		return err
+	}
+
+	return r.Save(filepath.Join(app.DownloadDir, feedFile))
+}
+
+// baseURL parses the given base URL of the download directory. If it
+// is empty, a file URL of the download directory is returned instead.
+func baseURL(base string) (*url.URL, error) {
+	if len(base) <= 0 {
+		abs, err := filepath.Abs(app.DownloadDir)
+		if err != nil {
+			return nil, err
+		}
+
+		base = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	} else if !u.IsAbs() {
+		return nil, fmt.Errorf("base URL %q is not absolute", base)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+		u.RawPath = ""
+	}
+
+	return u, nil
+}
+
+// buildFeed returns an RSS document containing the downloaded episodes
+// of the podcasts in the given directories of the download directory.
+// If combined is true, the episode titles are prefixed with the title
+// of their podcast, otherwise the feed is titled like the podcast.
+func buildFeed(base *url.URL, combined bool, dirs ...string) (*rss.RSS, error) {
+	r := rss.Create(app.Name, base.String())
+
+	states, err := stateDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		var title string
+		episodes := make(map[string]podcatcher.Episode)
+		if state, ok := states[dir]; ok {
+			title, episodes, err = podcatcher.ReadEpisodes(state)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(title) <= 0 {
+			title = strings.Replace(dir, "-", " ", -1)
+		}
+
+		if !combined {
+			r.Channel.Title = title
+			r.Channel.Description = title
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(app.DownloadDir, dir))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			ftype, ok := podcatcher.MediaType(f.Name())
+			if f.IsDir() || hidden(f.Name()) || !ok {
+				continue
+			}
+
+			info, ok := episodes[f.Name()]
+			if !ok {
+				name := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+				info = podcatcher.Episode{Title: strings.Replace(name, "-", " ", -1), Published: f.ModTime()}
+			}
+
+			if combined {
+				info.Title = title + ": " + info.Title
+			}
+
+			ref := &url.URL{Path: dir + "/" + f.Name()}
+			r.Add(info.Title, info.Published, base.ResolveReference(ref).String(), ftype, f.Size())
+		}
+	}
+
+	r.Sort()
+	return r, nil
+}
+
+// podcastDirs returns the names of all podcast directories in the
+// download directory.
+func podcastDirs() ([]string, error) {
+	files, err := ioutil.ReadDir(app.DownloadDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, f := range files {
+		if f.IsDir() && !hidden(f.Name()) {
+			dirs = append(dirs, f.Name())
+		}
+	}
+
+	return dirs, nil
+}
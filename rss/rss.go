@@ -0,0 +1,185 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rss implements a writer for RSS podcast feeds.
+// See also: https://www.rssboard.org/rss-specification
+package rss
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RSS version supported by this library.
+const version = "2.0"
+
+// RSS represents an RSS document.
+type RSS struct {
+	// XML name.
+	XMLName xml.Name `xml:"rss"`
+
+	// RSS standard version implemented by this file.
+	Version string `xml:"version,attr"`
+
+	// Channel described by this document.
+	Channel Channel `xml:"channel"`
+}
+
+// Channel represents an RSS channel.
+type Channel struct {
+	// Title of the channel.
+	Title string `xml:"title"`
+
+	// URL of the website corresponding to the channel.
+	Link string `xml:"link"`
+
+	// Description of the channel.
+	Description string `xml:"description"`
+
+	// Time the document was created.
+	Created string `xml:"lastBuildDate"`
+
+	// Array of items, each represents an episode.
+	Items []Item `xml:"item"`
+}
+
+// Item represents an RSS item with an enclosure.
+type Item struct {
+	// Title of the item.
+	Title string `xml:"title"`
+
+	// Unique identifier of the item.
+	GUID GUID `xml:"guid"`
+
+	// Time the item was published.
+	PubDate string `xml:"pubDate"`
+
+	// Media file of the item.
+	Enclosure Enclosure `xml:"enclosure"`
+}
+
+// GUID represents the unique identifier of an item.
+type GUID struct {
+	// Identifier of the item.
+	Value string `xml:",chardata"`
+
+	// Whether the identifier is an URL pointing to the item.
+	IsPermaLink bool `xml:"isPermaLink,attr"`
+}
+
+// Enclosure represents a media file attached to an item.
+type Enclosure struct {
+	// URL of the file.
+	URL string `xml:"url,attr"`
+
+	// Size of the file in bytes.
+	Length int64 `xml:"length,attr"`
+
+	// MIME type of the file.
+	Type string `xml:"type,attr"`
+}
+
+// Create returns a new RSS document with the given title and link.
+// However, this is just syntax sugar. A file is only written after a
+// call Save, it's the callers responsibility to do so if desired.
+func Create(title, link string) *RSS {
+	return &RSS{
+		Version: version,
+		Channel: Channel{
+			Title:       title,
+			Link:        link,
+			Description: title,
+			Created:     time.Now().Format(time.RFC1123Z),
+		},
+	}
+}
+
+// Add appends a new item with the given title and publication date for
+// the media file of the given type and size located at the given URL.
+// The URL is used as the GUID of the item.
+func (r *RSS) Add(title string, published time.Time, url, ftype string, length int64) {
+	item := Item{
+		Title:   title,
+		GUID:    GUID{Value: url, IsPermaLink: false},
+		PubDate: published.Format(time.RFC1123Z),
+		Enclosure: Enclosure{
+			URL:    url,
+			Length: length,
+			Type:   ftype,
+		},
+	}
+
+	r.Channel.Items = append(r.Channel.Items, item)
+}
+
+// Sort orders the items of the document by publication date, the most
+// recent item comes first.
+func (r *RSS) Sort() {
+	date := func(i int) time.Time {
+		t, _ := time.Parse(time.RFC1123Z, r.Channel.Items[i].PubDate)
+		return t
+	}
+
+	sort.SliceStable(r.Channel.Items, func(i, j int) bool {
+		return date(i).After(date(j))
+	})
+}
+
+// Write writes an indented version of the RSS document to the given
+// writer.
+func (r *RSS) Write(w io.Writer) error {
+	data, err := xml.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// Save writes an indented version of the RSS document to the given
+// file path. The file is replaced atomically, thus clients never
+// retrieve a partial document.
+func (r *RSS) Save(path string) error {
+	tmp := path + "." + strconv.Itoa(os.Getpid()) + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = r.Write(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
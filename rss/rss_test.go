@@ -0,0 +1,96 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rss
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	r := Create("Testcast", "http://example.com/")
+	if r.Channel.Title != "Testcast" {
+		t.Fatalf("Expected %q - got %q", "Testcast", r.Channel.Title)
+	}
+
+	if r.Version != version {
+		t.Fatalf("Expected %q - got %q", version, r.Version)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	published := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+	expected := Item{
+		Title:     "Episode 1",
+		GUID:      GUID{Value: "http://example.com/ep1.mp3"},
+		PubDate:   "Sun, 01 Mar 2015 12:00:00 +0000",
+		Enclosure: Enclosure{"http://example.com/ep1.mp3", 42, "audio/mpeg"},
+	}
+
+	r := new(RSS)
+	r.Add("Episode 1", published, "http://example.com/ep1.mp3", "audio/mpeg", 42)
+
+	if r.Channel.Items[0] != expected {
+		t.Fatalf("Expected %v - got %v", expected, r.Channel.Items[0])
+	}
+}
+
+func TestSort(t *testing.T) {
+	r := new(RSS)
+	r.Add("Old", time.Unix(1000, 0), "http://example.com/old.mp3", "audio/mpeg", 1)
+	r.Add("New", time.Unix(2000, 0), "http://example.com/new.mp3", "audio/mpeg", 1)
+
+	r.Sort()
+	if r.Channel.Items[0].Title != "New" {
+		t.Fatalf("Expected %q - got %q", "New", r.Channel.Items[0].Title)
+	}
+}
+
+func TestSave(t *testing.T) {
+	r := Create("Testcast", "http://example.com/")
+	r.Channel.Created = "Sun, 01 Mar 2015 12:00:00 +0000"
+	r.Add("Episode 1", time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC),
+		"http://example.com/ep1.mp3", "audio/mpeg", 42)
+
+	dir, err := ioutil.TempDir("", "rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "feed.rss")
+	if err := r.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := ioutil.ReadFile("testdata/testSave.rss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("Expected %q - got %q", expected, data)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/nmeum/cpod/directory"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/podcatcher"
+	"github.com/nmeum/cpod/store"
+	"os"
+	"strings"
+)
+
+// Podcast directories, configured by loadConfig.
+var (
+	itunes       directory.ITunes
+	podcastIndex directory.PodcastIndex
+)
+
+// search queries a podcast directory and prints the matching podcasts
+// and their feed URLs. Podcast Index is used by default if an API key
+// was configured, otherwise iTunes is used.
+func search(ctx context.Context, args []string) error {
+	def := "itunes"
+	if len(podcastIndex.Key) > 0 {
+		def = "podcastindex"
+	}
+
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	name := flags.String("d", def, "directory to search (itunes or podcastindex)")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: %s search [-d DIRECTORY] TERM...\n", app.Name)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if flags.NArg() <= 0 {
+		flags.Usage()
+		return errors.New("missing search term")
+	}
+
+	var dir directory.Directory
+	switch *name {
+	case "itunes":
+		dir = itunes
+	case "podcastindex":
+		dir = podcastIndex
+	default:
+		return fmt.Errorf("unknown directory %q", *name)
+	}
+
+	podcasts, err := dir.Search(ctx, strings.Join(flags.Args(), " "))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range podcasts {
+		if len(p.Author) > 0 {
+			fmt.Printf("%s (%s)\n\t%s\n", p.Title, p.Author, p.URL)
+		} else {
+			fmt.Printf("%s\n\t%s\n", p.Title, p.URL)
+		}
+	}
+
+	return nil
+}
+
+// add subscribes to the feed at the given URL using the given options.
+// Apple Podcasts URLs are resolved to the feed URL of the podcast and
+// websites are searched for feeds.
+func add(ctx context.Context, args []string) error {
+	if len(args) <= 0 {
+		return errors.New("USAGE: add URL [KEY=VALUE...]")
+	}
+
+	opts := make(store.Options)
+	for _, arg := range args[1:] {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			return fmt.Errorf("invalid option %q", arg)
+		}
+
+		opts[arg[0:i]] = arg[i+1:]
+	}
+
+	feedURL := args[0]
+	if id, ok := directory.AppleID(feedURL); ok {
+		p, err := itunes.Lookup(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s\n\t%s\n", p.Title, p.URL)
+		feedURL = p.URL
+	}
+
+	// Don't search invalid URLs for feeds.
+	if err := podcatcher.Validate(feedURL, opts); err != nil {
+		return err
+	}
+
+	feeds, err := store.Discover(ctx, feedURL)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range feeds {
+		if len(f.Title) > 0 {
+			fmt.Printf("%s\n\t%s\n", f.Title, f.URL)
+		} else {
+			fmt.Printf("%s\n", f.URL)
+		}
+	}
+
+	if len(feeds) > 1 {
+		return errors.New("multiple feeds found, add one of them")
+	} else if len(feeds) == 1 {
+		feedURL = feeds[0].URL
+	}
+
+	return catcher.Subscribe(feedURL, opts)
+}
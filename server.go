@@ -0,0 +1,342 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/podcatcher"
+	"github.com/nmeum/cpod/store"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Maximum size of a request body accepted by the API.
+const maxBodySize = 1 << 20
+
+// subscription represents a subscription in API requests and replies.
+type subscription struct {
+	// URL of the feed.
+	URL string `json:"url"`
+
+	// Options of the feed.
+	Options store.Options `json:"options,omitempty"`
+}
+
+// podcastFiles represents the downloaded episodes of a podcast.
+type podcastFiles struct {
+	// Name of the podcast directory.
+	Podcast string `json:"podcast"`
+
+	// Downloaded episodes.
+	Episodes []episodeFile `json:"episodes"`
+}
+
+// episodeFile represents a downloaded episode.
+type episodeFile struct {
+	// Name of the file.
+	Name string `json:"name"`
+
+	// Size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// Modification time of the file.
+	Modified time.Time `json:"modified"`
+
+	// Path of the file relative to the server root.
+	Path string `json:"path"`
+}
+
+// apiError is returned by API handlers to reply with a status code.
+type apiError struct {
+	code int
+	err  error
+}
+
+func (e apiError) Error() string {
+	return e.err.Error()
+}
+
+// newServer returns a handler for the HTTP API and the web interface.
+// A refresh of all feeds is requested by sending to the given channel.
+// If the given pusher isn't nil, its WebSub callbacks are served.
+func newServer(trigger chan<- bool, push *pusher) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", handleIndex)
+	mux.Handle("/files/", http.StripPrefix("/files/", fileServer()))
+	mux.Handle("/rss/", http.StripPrefix("/rss/", http.HandlerFunc(handleFeed)))
+	if push != nil {
+		mux.Handle("/websub/", http.StripPrefix("/websub", push.sub))
+	}
+
+	mux.HandleFunc("/api/subscriptions", apiHandler(handleSubscriptions))
+	mux.HandleFunc("/api/episodes", apiHandler(handleEpisodes))
+	mux.HandleFunc("/api/status", apiHandler(handleStatus))
+	mux.HandleFunc("/api/refresh", apiHandler(func(r *http.Request) (interface{}, error) {
+		if r.Method != "POST" {
+			return nil, apiError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+		}
+
+		select {
+		case trigger <- true:
+		default: // Refresh already requested
+		}
+
+		return activity.report(), nil
+	}))
+
+	return mux
+}
+
+// apiHandler converts the given function to an HTTP handler which
+// replies with the JSON encoding of the returned value. Requests which
+// modify state must be sent as JSON, see checkContentType.
+func apiHandler(fn func(*http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var v interface{}
+		err := checkContentType(r)
+		if err == nil {
+			v, err = fn(r)
+		}
+		if err != nil {
+			code := http.StatusInternalServerError
+			if aerr, ok := err.(apiError); ok {
+				code = aerr.code
+			}
+
+			w.WriteHeader(code)
+			v = map[string]string{"error": err.Error()}
+		}
+
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			app.Logger.Println(err)
+		}
+	}
+}
+
+// checkContentType rejects requests, other than GET requests, which
+// aren't sent as JSON. Web pages can't send such requests to another
+// origin without a CORS preflight, thereby preventing cross-site
+// request forgery.
+func checkContentType(r *http.Request) error {
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return nil
+	}
+
+	t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || t != "application/json" {
+		return apiError{http.StatusUnsupportedMediaType, errors.New("content type must be application/json")}
+	}
+
+	return nil
+}
+
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, indexPage)
+}
+
+// handleFeed serves the RSS feed of the podcast with the given name or
+// the combined feed of all podcasts if no name was given. The enclosure
+// URLs point to the files served by this server.
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	dirs, err := podcastDirs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := strings.TrimSuffix(r.URL.Path, "/")
+	if len(name) > 0 {
+		dirs = nil
+		if fi, err := os.Stat(filepath.Join(app.DownloadDir, name)); err == nil && fi.IsDir() &&
+			!hidden(name) && !strings.Contains(name, "/") {
+			dirs = []string{name}
+		}
+
+		if len(dirs) <= 0 {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	base := &url.URL{Scheme: "http", Host: r.Host, Path: "/files/"}
+	if r.TLS != nil {
+		base.Scheme = "https"
+	}
+
+	feed, err := buildFeed(base, len(name) <= 0, dirs...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	if err := feed.Write(w); err != nil {
+		app.Logger.Println(err)
+	}
+}
+
+func handleSubscriptions(r *http.Request) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		return listSubscriptions()
+	case "POST":
+		var sub subscription
+
+		data, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+		if err == nil {
+			err = json.Unmarshal(data, &sub)
+		}
+
+		if err != nil {
+			return nil, apiError{http.StatusBadRequest, err}
+		}
+
+		if err := catcher.Subscribe(sub.URL, sub.Options); err != nil {
+			return nil, subscriptionError(err)
+		}
+
+		return sub, nil
+	case "DELETE":
+		feedURL := r.URL.Query().Get("url")
+		if err := catcher.Unsubscribe(feedURL); err != nil {
+			return nil, subscriptionError(err)
+		}
+
+		return subscription{URL: feedURL}, nil
+	}
+
+	return nil, apiError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+}
+
+func handleEpisodes(r *http.Request) (interface{}, error) {
+	if r.Method != "GET" {
+		return nil, apiError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	return listEpisodes()
+}
+
+func handleStatus(r *http.Request) (interface{}, error) {
+	if r.Method != "GET" {
+		return nil, apiError{http.StatusMethodNotAllowed, errors.New("method not allowed")}
+	}
+
+	return activity.report(), nil
+}
+
+// listSubscriptions returns all subscriptions of the store.
+func listSubscriptions() (subs []subscription, err error) {
+	storage, err := app.LoadStore()
+	if err != nil {
+		return nil, err
+	}
+
+	subs = []subscription{}
+	for _, u := range storage.URLs() {
+		subs = append(subs, subscription{u, storage.Options(u)})
+	}
+
+	return
+}
+
+// subscriptionError converts errors returned when modifying the
+// subscriptions to API errors with a corresponding status code.
+func subscriptionError(err error) error {
+	switch {
+	case errors.Is(err, podcatcher.ErrInvalidURL), errors.Is(err, podcatcher.ErrInvalidOptions):
+		return apiError{http.StatusBadRequest, err}
+	case errors.Is(err, podcatcher.ErrSubscribed):
+		return apiError{http.StatusConflict, err}
+	case errors.Is(err, podcatcher.ErrNotSubscribed):
+		return apiError{http.StatusNotFound, err}
+	}
+
+	return err
+}
+
+// listEpisodes returns all downloaded episodes grouped by podcast.
+// Hidden files and partial downloads are omitted.
+func listEpisodes() ([]podcastFiles, error) {
+	dirs, err := podcastDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	podcasts := []podcastFiles{}
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(filepath.Join(app.DownloadDir, dir))
+		if err != nil {
+			return nil, err
+		}
+
+		p := podcastFiles{dir, []episodeFile{}}
+		for _, f := range files {
+			if f.IsDir() || hidden(f.Name()) || f.Name() == feedFile || strings.HasSuffix(f.Name(), ".part") {
+				continue
+			}
+
+			path := "/files/" + url.PathEscape(dir) + "/" + url.PathEscape(f.Name())
+			p.Episodes = append(p.Episodes, episodeFile{f.Name(), f.Size(), f.ModTime(), path})
+		}
+
+		sort.Slice(p.Episodes, func(i, j int) bool {
+			return p.Episodes[i].Modified.After(p.Episodes[j].Modified)
+		})
+
+		podcasts = append(podcasts, p)
+	}
+
+	return podcasts, nil
+}
+
+// fileServer returns a handler serving the download directory. Hidden
+// files, e.g. markers, are not served.
+func fileServer() http.Handler {
+	fs := http.FileServer(http.Dir(app.DownloadDir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, elem := range strings.Split(r.URL.Path, "/") {
+			if hidden(elem) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		fs.ServeHTTP(w, r)
+	})
+}
+
+// hidden returns true if the given file name denotes a hidden file.
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
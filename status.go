@@ -0,0 +1,150 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// download describes a running download.
+type download struct {
+	// Title of the podcast.
+	Podcast string `json:"podcast"`
+
+	// Title of the episode.
+	Episode string `json:"episode"`
+
+	// URL of the episode file.
+	URL string `json:"url"`
+
+	// Time the download was started.
+	Started time.Time `json:"started"`
+}
+
+// report is a snapshot of the current activity.
+type report struct {
+	// Feeds which will be fetched during the running refresh.
+	Queue []string `json:"queue"`
+
+	// Running downloads.
+	Downloads []download `json:"downloads"`
+
+	// Time the last refresh finished.
+	LastRefresh time.Time `json:"last_refresh"`
+
+	// Time of the next refresh of each feed in daemon mode.
+	Schedule map[string]time.Time `json:"schedule"`
+}
+
+// status tracks the activity of update. It is safe for concurrent use.
+type status struct {
+	mu        sync.Mutex
+	queue     map[string]bool
+	downloads map[string]download
+	last      time.Time
+	next      schedule
+}
+
+// activity tracks the activity of this process.
+var activity = &status{
+	queue:     make(map[string]bool),
+	downloads: make(map[string]download),
+	next:      make(schedule),
+}
+
+// enqueue adds the given feeds to the queue.
+func (s *status) enqueue(urls []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, url := range urls {
+		s.queue[url] = true
+	}
+}
+
+// dequeue removes the given feed from the queue.
+func (s *status) dequeue(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queue, url)
+}
+
+// finish empties the queue and records the end of a refresh.
+func (s *status) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queue = make(map[string]bool)
+	s.last = time.Now()
+}
+
+// start records the start of the download of an episode.
+func (s *status) start(podcast, episode, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.downloads[url] = download{podcast, episode, url, time.Now()}
+}
+
+// done records the end of the download of an episode.
+func (s *status) done(url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.downloads, url)
+}
+
+// reschedule replaces the refresh schedule.
+func (s *status) reschedule(next schedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.next = make(schedule)
+	for url, t := range next {
+		s.next[url] = t
+	}
+}
+
+// report returns a snapshot of the current activity.
+func (s *status) report() report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := report{
+		Queue:       []string{},
+		Downloads:   []download{},
+		LastRefresh: s.last,
+		Schedule:    make(map[string]time.Time),
+	}
+
+	for url := range s.queue {
+		r.Queue = append(r.Queue, url)
+	}
+	sort.Strings(r.Queue)
+
+	for _, d := range s.downloads {
+		r.Downloads = append(r.Downloads, d)
+	}
+	sort.Slice(r.Downloads, func(i, j int) bool {
+		return r.Downloads[i].Started.Before(r.Downloads[j].Started)
+	})
+
+	for url, t := range s.next {
+		r.Schedule[url] = t
+	}
+
+	return r
+}
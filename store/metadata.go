@@ -0,0 +1,95 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the file in the cache directory of a podcast which contains
+// its metadata.
+const metadataFile = "feed.json"
+
+// Metadata contains information about a feed which is cached to avoid
+// fetching the feed if only the information is required.
+type Metadata struct {
+	// Title of the feed.
+	Title string `json:"title"`
+
+	// Type of the feed, e.g. rss or atom.
+	Type string `json:"type"`
+
+	// URL of the website the feed belongs to.
+	Link string `json:"link,omitempty"`
+}
+
+// Dir returns the directory of the podcast below the given directory,
+// e.g. the directory containing its state below the state directory.
+func (p Podcast) Dir(base string) string {
+	return filepath.Join(base, p.ID())
+}
+
+// Tags returns the tags of the podcast, specified as comma separated
+// list using the tags option.
+func (p Podcast) Tags() []string {
+	var tags []string
+	for _, tag := range strings.Split(p.Options["tags"], ",") {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// Metadata returns the metadata of the fetched feed.
+func (p Podcast) Metadata() Metadata {
+	return Metadata{
+		Title: strings.Join(strings.Fields(p.Feed.Title), " "),
+		Type:  p.Feed.Type,
+		Link:  strings.TrimSpace(p.Feed.Link),
+	}
+}
+
+// LoadMetadata reads the cached metadata from the given cache
+// directory of a podcast.
+func LoadMetadata(dir string) (m Metadata, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, metadataFile))
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &m)
+	return
+}
+
+// Save writes the metadata to the given cache directory of a podcast.
+func (m Metadata) Save(dir string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, metadataFile), append(data, '\n'), 0644)
+}
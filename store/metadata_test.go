@@ -0,0 +1,68 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cpod-metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := Podcast{URL: "http://example.com/feed.rss", Options: Options{"id": "example"}}
+	p.Feed.Title = " Example\n Podcast "
+	p.Feed.Type = "rss"
+
+	state := p.Dir(dir)
+	if state != filepath.Join(dir, "example") {
+		t.Fatalf("Expected %q - got %q", filepath.Join(dir, "example"), state)
+	}
+
+	if err := p.Metadata().Save(state); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadMetadata(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Metadata{Title: "Example Podcast", Type: "rss"}
+	if m != expected {
+		t.Fatalf("Expected %q - got %q", expected, m)
+	}
+}
+
+func TestTags(t *testing.T) {
+	p := Podcast{Options: Options{"tags": "news, tech,,"}}
+	expected := []string{"news", "tech"}
+
+	if tags := p.Tags(); !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("Expected %q - got %q", expected, tags)
+	}
+
+	if tags := (Podcast{}).Tags(); len(tags) != 0 {
+		t.Fatalf("Expected %d - got %d", 0, len(tags))
+	}
+}
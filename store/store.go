@@ -17,23 +17,96 @@ package store
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/nmeum/cpod/extension"
 	"github.com/nmeum/cpod/util"
 	"github.com/nmeum/go-feedparser"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
+// Options represents per-feed options. In the URL file they are
+// specified as whitespace separated key=value pairs after the URL,
+// values containing whitespaces need to be double quoted.
+type Options map[string]string
+
 // Podcast represents a Podcast loaded from the store.
 type Podcast struct {
 	// URL to the feed.
 	URL string
 
+	// Options specified for the feed.
+	Options Options
+
 	// Feed itself.
 	Feed feedparser.Feed
 
+	// Extension data of the feed.
+	Extension extension.Feed
+
+	// New URL of the feed if it moved permanently, either using a
+	// permanent redirect or itunes:new-feed-url.
+	Moved string
+
 	// Error if parsing failed.
 	Error error
 }
 
+// Regex matching identifiers which are safe to use as file names.
+var validID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// ID returns the stable identifier of the podcast. The id option takes
+// precedence, otherwise the podcast:guid of the feed or a hash of the
+// feed URL is used. The identifier is safe to use as a file name.
+func (p Podcast) ID() string {
+	if id := p.Options["id"]; validID.MatchString(id) {
+		return id
+	} else if guid := strings.ToLower(p.Extension.GUID); validID.MatchString(guid) {
+		return guid
+	}
+
+	hash := sha256.Sum256([]byte(p.URL))
+	return hex.EncodeToString(hash[0:8])
+}
+
+// ErrGone is reported for feeds which no longer exist (HTTP 410).
+var ErrGone = errors.New("feed is gone")
+
+// DiscoveryError is reported if a URL points to an HTML page which
+// doesn't reference exactly one feed.
+type DiscoveryError struct {
+	// URL of the HTML page.
+	URL string
+
+	// Feeds referenced by the page.
+	Feeds []util.Alternate
+}
+
+func (e *DiscoveryError) Error() string {
+	if len(e.Feeds) <= 0 {
+		return fmt.Sprintf("%s: no feed found", e.URL)
+	}
+
+	var urls []string
+	for _, f := range e.Feeds {
+		urls = append(urls, f.URL)
+	}
+
+	return fmt.Sprintf("%s: multiple feeds found: %s", e.URL, strings.Join(urls, ", "))
+}
+
 // Store represents a storage backend.
 type Store struct {
 	// path describes the URL file location.
@@ -41,6 +114,9 @@ type Store struct {
 
 	// urls contains all URLs which are part of the URL file.
 	urls []string
+
+	// opts contains the options of each URL.
+	opts map[string]Options
 }
 
 // Load returns and creates a new store with the URL file located
@@ -57,8 +133,18 @@ func Load(path string) (s *Store, err error) {
 	defer file.Close()
 	scanner := bufio.NewScanner(file)
 
-	for scanner.Scan() {
-		s.urls = append(s.urls, scanner.Text())
+	for n := 1; scanner.Scan(); n++ {
+		url, opts, lerr := parseLine(scanner.Text())
+		if lerr != nil {
+			return s, fmt.Errorf("%s:%d: %s", path, n, lerr)
+		} else if len(url) <= 0 {
+			continue
+		}
+
+		s.urls = append(s.urls, url)
+		if len(opts) > 0 {
+			s.SetOptions(url, opts)
+		}
 	}
 
 	err = scanner.Err()
@@ -72,6 +158,84 @@ func (s *Store) Add(url string) {
 	s.urls = append(s.urls, url)
 }
 
+// Remove removes the given URL and its options from the store. It
+// returns false if the URL isn't a part of the store.
+func (s *Store) Remove(url string) bool {
+	for i, u := range s.urls {
+		if u == url {
+			s.urls = append(s.urls[0:i], s.urls[i+1:]...)
+			delete(s.opts, url)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Move replaces the given old URL with the given new URL, the options
+// of the feed are retained. If the new URL is already a part of the
+// store, the old URL is removed instead. It returns false if the old
+// URL isn't a part of the store.
+func (s *Store) Move(old, new string) bool {
+	if s.Contains(new) {
+		return s.Remove(old)
+	}
+
+	for i, u := range s.urls {
+		if u == old {
+			s.urls[i] = new
+			if opts, ok := s.opts[old]; ok {
+				delete(s.opts, old)
+				s.opts[new] = opts
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// Options returns the options of the given URL.
+func (s *Store) Options(url string) Options {
+	return s.opts[url]
+}
+
+// SetOptions replaces the options of the given URL.
+func (s *Store) SetOptions(url string, opts Options) {
+	if s.opts == nil {
+		s.opts = make(map[string]Options)
+	}
+
+	s.opts[url] = opts
+}
+
+// URLs returns all URLs which are part of the store.
+func (s *Store) URLs() []string {
+	urls := make([]string, len(s.urls))
+	copy(urls, s.urls)
+	return urls
+}
+
+// Subset returns a new store containing only the given URLs of the
+// store, including their options. URLs which are not part of the
+// store are ignored.
+func (s *Store) Subset(urls []string) *Store {
+	subset := &Store{path: s.path}
+	for _, url := range urls {
+		if !s.Contains(url) {
+			continue
+		}
+
+		subset.Add(url)
+		if opts, ok := s.opts[url]; ok {
+			subset.SetOptions(url, opts)
+		}
+	}
+
+	return subset
+}
+
 // Contains returns true if the url is already a part of the
 // store. If it isn't it returns false.
 func (s *Store) Contains(url string) bool {
@@ -85,42 +249,260 @@ func (s *Store) Contains(url string) bool {
 }
 
 // Fetch fetches all feeds form the urls and returns a channel
-// which contains all podcasts.
-func (s *Store) Fetch() <-chan Podcast {
+// which contains all podcasts. No further feeds are fetched and
+// the channel is closed once the given context is canceled.
+func (s *Store) Fetch(ctx context.Context) <-chan Podcast {
 	out := make(chan Podcast)
 	go func() {
+		defer close(out)
 		for _, url := range s.urls {
-			resp, err := util.Get(url)
+			cast, err := s.fetch(ctx, url)
 			if err != nil {
 				continue
 			}
 
-			reader := resp.Body
-			defer reader.Close()
-
-			f, err := feedparser.Parse(reader)
-			out <- Podcast{url, f, err}
+			select {
+			case out <- cast:
+			case <-ctx.Done():
+				return
+			}
 		}
-
-		close(out)
 	}()
 
 	return out
 }
 
-// Save writes the URL file to the store path.
+// FetchFeed fetches the feed located at the given URL. The URL doesn't
+// need to be a part of the store.
+func (s *Store) FetchFeed(ctx context.Context, url string) Podcast {
+	cast, err := s.fetch(ctx, url)
+	if err != nil {
+		return Podcast{URL: url, Options: s.opts[url], Error: err}
+	}
+
+	return cast
+}
+
+// fetch fetches and parses the feed located at the given URL. If the
+// URL points to an HTML page referencing exactly one feed, that feed
+// is fetched instead. Network errors are returned, other errors are
+// reported in the returned podcast.
+func (s *Store) fetch(ctx context.Context, url string) (Podcast, error) {
+	data, resp, feeds, err := discover(ctx, url)
+	if err != nil {
+		return Podcast{}, err
+	} else if resp.StatusCode == http.StatusGone {
+		return Podcast{URL: url, Options: s.opts[url], Error: fmt.Errorf("%s: %w", url, ErrGone)}, nil
+	}
+
+	switch {
+	case feeds == nil:
+		cast := s.parse(url, data)
+		cast.Moved = movedTo(resp)
+
+		newURL := cast.Extension.NewFeedURL
+		if cast.Error == nil && newURL != url && isFeedURL(newURL) {
+			cast.Moved = newURL
+		}
+
+		return cast, nil
+	case len(feeds) == 1:
+		if data, _, err = get(ctx, feeds[0].URL); err != nil {
+			return Podcast{}, err
+		}
+
+		return s.parse(url, data), nil
+	}
+
+	return Podcast{URL: url, Options: s.opts[url], Error: &DiscoveryError{url, feeds}}, nil
+}
+
+// Discover returns the feeds referenced by the HTML page located at
+// the given URL. If the URL points to a feed, nil is returned. If the
+// page doesn't reference any feed, a DiscoveryError is returned.
+func Discover(ctx context.Context, url string) ([]util.Alternate, error) {
+	_, _, feeds, err := discover(ctx, url)
+	if err == nil && feeds != nil && len(feeds) <= 0 {
+		err = &DiscoveryError{url, feeds}
+	}
+
+	return feeds, err
+}
+
+// discover retrieves the document located at the given URL. If it is
+// an HTML page, the feeds referenced by it are returned as well, the
+// returned slice is only nil if the document isn't an HTML page.
+func discover(ctx context.Context, url string) ([]byte, *http.Response, []util.Alternate, error) {
+	data, resp, err := get(ctx, url)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if resp.StatusCode == http.StatusGone || !util.IsHTML(resp.Header.Get("Content-Type"), data) {
+		return data, resp, nil, nil
+	}
+
+	feeds, err := util.Alternates(bytes.NewReader(data), resp.Request.URL)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if len(feeds) > 0 {
+		return data, resp, feeds, nil
+	}
+
+	// Some feeds are served as HTML, treat those as feeds.
+	if _, err := feedparser.Parse(bytes.NewReader(data)); err == nil {
+		return data, resp, nil, nil
+	}
+
+	return data, resp, []util.Alternate{}, nil
+}
+
+// movedTo returns the final URL of the given response if the request
+// was only redirected permanently. Otherwise, an empty string is
+// returned.
+func movedTo(resp *http.Response) string {
+	req := resp.Request
+	if req.Response == nil {
+		return ""
+	}
+
+	for r := req; r.Response != nil; r = r.Response.Request {
+		switch r.Response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+		default:
+			return ""
+		}
+	}
+
+	return req.URL.String()
+}
+
+// isFeedURL returns true if the given string is an absolute HTTP URL.
+func isFeedURL(str string) bool {
+	u, err := neturl.Parse(str)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+}
+
+// get retrieves the document located at the given URL.
+func get(ctx context.Context, url string) ([]byte, *http.Response, error) {
+	resp, err := util.Get(ctx, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, resp, nil
+}
+
+// parse parses the given feed.
+func (s *Store) parse(url string, data []byte) Podcast {
+	var e extension.Feed
+	f, err := feedparser.Parse(bytes.NewReader(data))
+	if err == nil {
+		e, err = extension.Parse(bytes.NewReader(data))
+	}
+
+	return Podcast{URL: url, Options: s.opts[url], Feed: f, Extension: e, Error: err}
+}
+
+// Save writes the URL file to the store path. The file is replaced
+// atomically, thus concurrent readers never see a partial file.
 func (s *Store) Save() error {
-	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	tmp := s.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
 
-	defer file.Close()
 	for _, url := range s.urls {
-		if _, err := file.WriteString(url + "\n"); err != nil {
-			return err
+		line := formatLine(url, s.opts[url])
+		if _, err = file.WriteString(line + "\n"); err != nil {
+			break
+		}
+	}
+
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// parseLine parses a line of the URL file and returns the URL and the
+// options specified on the given line.
+func parseLine(line string) (url string, opts Options, err error) {
+	line = strings.TrimSpace(line)
+	i := strings.IndexFunc(line, unicode.IsSpace)
+	if i < 0 {
+		return line, nil, nil
+	}
+
+	opts = make(Options)
+	url, line = line[0:i], strings.TrimLeftFunc(line[i:], unicode.IsSpace)
+
+	for len(line) > 0 {
+		i = strings.IndexFunc(line, func(r rune) bool {
+			return r == '=' || unicode.IsSpace(r)
+		})
+		if i <= 0 || line[i] != '=' {
+			return "", nil, fmt.Errorf("invalid option %q", line)
+		}
+
+		var value string
+		key := line[0:i]
+		line = line[i+1:]
+
+		if strings.HasPrefix(line, "\"") {
+			var quoted string
+			if quoted, err = strconv.QuotedPrefix(line); err != nil {
+				return "", nil, fmt.Errorf("invalid value for option %q", key)
+			}
+
+			value, _ = strconv.Unquote(quoted)
+			line = line[len(quoted):]
+		} else {
+			i = strings.IndexFunc(line, unicode.IsSpace)
+			if i < 0 {
+				i = len(line)
+			}
+
+			value, line = line[0:i], line[i:]
+		}
+
+		opts[key] = value
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+	}
+
+	return
+}
+
+// formatLine formats the given URL and options as a line of the URL
+// file. Options are sorted by key and quoted if necessary.
+func formatLine(url string, opts Options) string {
+	var keys []string
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	line := url
+	for _, key := range keys {
+		value := opts[key]
+		if len(value) <= 0 || strings.HasPrefix(value, "\"") ||
+			strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+			value = strconv.Quote(value)
 		}
+
+		line += fmt.Sprintf(" %s=%s", key, value)
 	}
 
-	return nil
+	return line
 }
@@ -16,9 +16,14 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -38,6 +43,71 @@ func TestLoad(t *testing.T) {
 			t.Fail()
 		}
 	}
+
+	opts := store.opts["http://example.com/feed.rss"]
+	if opts["exclude"] != "(?i)trailer|ad break" {
+		t.Fatalf("Expected %q - got %q", "(?i)trailer|ad break", opts["exclude"])
+	}
+
+	if opts["type"] != "audio/*" {
+		t.Fatalf("Expected %q - got %q", "audio/*", opts["type"])
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	type testpair struct {
+		line string
+		url  string
+		opts Options
+	}
+
+	tests := []testpair{
+		{"http://a.com", "http://a.com", nil},
+		{"  http://a.com\t", "http://a.com", nil},
+		{"http://a.com foo=bar", "http://a.com", Options{"foo": "bar"}},
+		{"http://a.com a=1  b=\"x y\"", "http://a.com", Options{"a": "1", "b": "x y"}},
+		{"http://a.com a=", "http://a.com", Options{"a": ""}},
+	}
+
+	for _, test := range tests {
+		url, opts, err := parseLine(test.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if url != test.url {
+			t.Fatalf("Expected %q - got %q", test.url, url)
+		}
+
+		if !reflect.DeepEqual(opts, test.opts) {
+			t.Fatalf("Expected %v - got %v", test.opts, opts)
+		}
+	}
+
+	for _, line := range []string{"http://a.com foo", "http://a.com a=\"b"} {
+		if _, _, err := parseLine(line); err == nil {
+			t.Fatalf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	opts := Options{"b": "x y", "a": "1"}
+	line := formatLine("http://a.com", opts)
+
+	expected := "http://a.com a=1 b=\"x y\""
+	if line != expected {
+		t.Fatalf("Expected %q - got %q", expected, line)
+	}
+
+	url, parsed, err := parseLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if url != "http://a.com" || !reflect.DeepEqual(parsed, opts) {
+		t.Fatalf("Expected %v - got %v", opts, parsed)
+	}
 }
 
 func TestAdd(t *testing.T) {
@@ -52,7 +122,7 @@ func TestAdd(t *testing.T) {
 
 func TestContains(t *testing.T) {
 	url := "http://foo.com"
-	store := &Store{"", []string{url}}
+	store := &Store{path: "", urls: []string{url}}
 
 	if !store.Contains(url) {
 		t.Fail()
@@ -63,11 +133,25 @@ func TestContains(t *testing.T) {
 	}
 }
 
+func TestSubset(t *testing.T) {
+	store := &Store{path: "", urls: []string{"http://a.com", "http://b.com"}}
+	store.SetOptions("http://b.com", Options{"foo": "bar"})
+
+	subset := store.Subset([]string{"http://b.com", "http://c.com"})
+	if !reflect.DeepEqual(subset.URLs(), []string{"http://b.com"}) {
+		t.Fatalf("Expected %q - got %q", []string{"http://b.com"}, subset.URLs())
+	}
+
+	if subset.opts["http://b.com"]["foo"] != "bar" {
+		t.Fail()
+	}
+}
+
 func TestFetch(t *testing.T) {
 	url := "http://feed.thisamericanlife.org/talpodcast"
-	store := &Store{"", []string{url}}
+	store := &Store{path: "", urls: []string{url}}
 
-	channel := store.Fetch()
+	channel := store.Fetch(context.Background())
 	podcast := <-channel
 
 	if podcast.Error != nil {
@@ -85,11 +169,197 @@ func TestFetch(t *testing.T) {
 	}
 }
 
+func TestFetchFeed(t *testing.T) {
+	th := func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/testFetchFeed.rss")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(th))
+	defer ts.Close()
+
+	store := new(Store)
+	store.SetOptions(ts.URL, Options{"foo": "bar"})
+
+	podcast := store.FetchFeed(context.Background(), ts.URL)
+	if podcast.Error != nil {
+		t.Fatal(podcast.Error)
+	}
+
+	if podcast.Feed.Title != "Testcast" {
+		t.Fatalf("Expected %q - got %q", "Testcast", podcast.Feed.Title)
+	}
+
+	if podcast.Options["foo"] != "bar" {
+		t.Fatalf("Expected %q - got %q", "bar", podcast.Options["foo"])
+	}
+
+	if podcast.Extension.Link("next") != "?page=2" {
+		t.Fatalf("Expected %q - got %q", "?page=2", podcast.Extension.Link("next"))
+	}
+}
+
+func TestFetchFeedDiscovery(t *testing.T) {
+	th := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.ServeFile(w, r, "testdata/testDiscover.html")
+		case "/feed.rss":
+			http.ServeFile(w, r, "testdata/testFetchFeed.rss")
+		case "/empty":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><body>No feeds</body></html>"))
+		}
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(th))
+	defer ts.Close()
+
+	store := new(Store)
+	podcast := store.FetchFeed(context.Background(), ts.URL+"/")
+	if podcast.Error != nil {
+		t.Fatal(podcast.Error)
+	}
+
+	if podcast.Feed.Title != "Testcast" || podcast.URL != ts.URL+"/" {
+		t.Fatalf("Expected %q - got %q", "Testcast", podcast.Feed.Title)
+	}
+
+	feeds, err := Discover(context.Background(), ts.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(feeds) != 1 || feeds[0].URL != ts.URL+"/feed.rss" {
+		t.Fatalf("Expected %q - got %v", ts.URL+"/feed.rss", feeds)
+	}
+
+	if feeds, err := Discover(context.Background(), ts.URL+"/feed.rss"); err != nil || feeds != nil {
+		t.Fatalf("Expected no feeds - got %v, %v", feeds, err)
+	}
+
+	podcast = store.FetchFeed(context.Background(), ts.URL+"/empty")
+	if _, ok := podcast.Error.(*DiscoveryError); !ok {
+		t.Fatalf("Expected DiscoveryError - got %v", podcast.Error)
+	}
+}
+
+func TestFetchFeedMoved(t *testing.T) {
+	newFeed := `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+	<channel>
+		<title>Testcast</title>
+		<itunes:new-feed-url>http://example.org/feed.rss</itunes:new-feed-url>
+	</channel>
+</rss>`
+
+	th := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/older":
+			http.Redirect(w, r, "/old", http.StatusPermanentRedirect)
+		case "/temporary":
+			http.Redirect(w, r, "/old", http.StatusFound)
+		case "/new":
+			http.ServeFile(w, r, "testdata/testFetchFeed.rss")
+		case "/announced":
+			w.Write([]byte(newFeed))
+		case "/gone":
+			http.Error(w, "gone", http.StatusGone)
+		}
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(th))
+	defer ts.Close()
+
+	tests := []struct {
+		path  string
+		moved string
+	}{
+		{"/new", ""},
+		{"/old", ts.URL + "/new"},
+		{"/older", ts.URL + "/new"},
+		{"/temporary", ""},
+		{"/announced", "http://example.org/feed.rss"},
+	}
+
+	store := new(Store)
+	for _, test := range tests {
+		podcast := store.FetchFeed(context.Background(), ts.URL+test.path)
+		if podcast.Error != nil {
+			t.Fatal(podcast.Error)
+		}
+
+		if podcast.Moved != test.moved {
+			t.Fatalf("Expected %q - got %q", test.moved, podcast.Moved)
+		}
+	}
+
+	podcast := store.FetchFeed(context.Background(), ts.URL+"/gone")
+	if !errors.Is(podcast.Error, ErrGone) {
+		t.Fatalf("Expected %q - got %q", ErrGone, podcast.Error)
+	}
+}
+
+func TestID(t *testing.T) {
+	p := Podcast{URL: "http://example.com/feed.rss"}
+	if p.ID() != "622d4518a821695b" {
+		t.Fatalf("Expected %q - got %q", "622d4518a821695b", p.ID())
+	}
+
+	p.Extension.GUID = "917393E3-1b1e-5cef-ace4-edaa54e1f810"
+	if p.ID() != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("Expected %q - got %q", "917393e3-1b1e-5cef-ace4-edaa54e1f810", p.ID())
+	}
+
+	p.Options = Options{"id": "daily"}
+	if p.ID() != "daily" {
+		t.Fatalf("Expected %q - got %q", "daily", p.ID())
+	}
+
+	p.Options = Options{"id": "../escape"}
+	if p.ID() != "917393e3-1b1e-5cef-ace4-edaa54e1f810" {
+		t.Fatalf("Expected %q - got %q", "917393e3-1b1e-5cef-ace4-edaa54e1f810", p.ID())
+	}
+}
+
+func TestMove(t *testing.T) {
+	store := &Store{path: "", urls: []string{"http://a.com", "http://b.com", "http://c.com"}}
+	store.SetOptions("http://a.com", Options{"foo": "bar"})
+
+	if !store.Move("http://a.com", "http://d.com") {
+		t.Fail()
+	}
+
+	expected := []string{"http://d.com", "http://b.com", "http://c.com"}
+	if !reflect.DeepEqual(store.URLs(), expected) {
+		t.Fatalf("Expected %q - got %q", expected, store.URLs())
+	}
+
+	if store.Options("http://d.com")["foo"] != "bar" || store.Options("http://a.com") != nil {
+		t.Fail()
+	}
+
+	// Moving to an existing URL removes the duplicate.
+	if !store.Move("http://b.com", "http://c.com") {
+		t.Fail()
+	}
+
+	expected = []string{"http://d.com", "http://c.com"}
+	if !reflect.DeepEqual(store.URLs(), expected) {
+		t.Fatalf("Expected %q - got %q", expected, store.URLs())
+	}
+
+	if store.Move("http://x.com", "http://y.com") {
+		t.Fail()
+	}
+}
+
 func TestSave(t *testing.T) {
 	url := "http://example.io"
 	fp := filepath.Join(os.TempDir(), "testSave")
 
-	store := &Store{fp, []string{url}}
+	store := &Store{path: fp, urls: []string{url}}
 	if err := store.Save(); err != nil {
 		t.Fatal(err)
 	}
@@ -104,4 +374,35 @@ func TestSave(t *testing.T) {
 	if string(data) != expected {
 		t.Fatalf("Expected %q - got %q", string(data), expected)
 	}
+
+	// Saving again must not duplicate existing URLs.
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = ioutil.ReadFile(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != expected {
+		t.Fatalf("Expected %q - got %q", string(data), expected)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	store := &Store{path: "", urls: []string{"http://a.com", "http://b.com"}}
+	store.SetOptions("http://a.com", Options{"foo": "bar"})
+
+	if !store.Remove("http://a.com") {
+		t.Fail()
+	}
+
+	if store.Contains("http://a.com") || store.Options("http://a.com") != nil {
+		t.Fail()
+	}
+
+	if !store.Contains("http://b.com") || store.Remove("http://c.com") {
+		t.Fail()
+	}
 }
@@ -0,0 +1,277 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nmeum/cpod/gpodder"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/store"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Timeout of a synchronization with the gpodder.net server.
+const syncTimeout = 2 * time.Minute
+
+// Client of the gpodder.net server, configured by loadConfig.
+var gpodderClient gpodder.Client
+
+// syncState represents the state of the last synchronization, it is
+// stored next to the URL file.
+type syncState struct {
+	// Server, user and device of the last synchronization. The state
+	// is discarded if they change.
+	Account string `json:"account"`
+
+	// Server time of the last synchronization.
+	Timestamp int64 `json:"timestamp"`
+
+	// Subscriptions after the last synchronization.
+	Subscriptions []string `json:"subscriptions"`
+
+	// URLs of episodes whose download was uploaded.
+	Uploaded map[string]bool `json:"uploaded"`
+}
+
+func syncStatePath() string {
+	return filepath.Join(app.StateDir, "gpodder.json")
+}
+
+func loadSyncState(account string) (*syncState, error) {
+	state := &syncState{Account: account}
+
+	data, err := ioutil.ReadFile(syncStatePath())
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if state.Account != account {
+		state = &syncState{Account: account}
+	}
+	if state.Uploaded == nil {
+		state.Uploaded = make(map[string]bool)
+	}
+
+	return state, nil
+}
+
+func (s *syncState) save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(app.StateDir, 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(syncStatePath(), append(data, '\n'), 0600)
+}
+
+// syncGpodder merges the subscriptions of the store with those of the
+// device on the gpodder.net server. Local changes since the last sync
+// take precedence over remote ones. Afterwards, download actions of all
+// downloaded episodes which weren't uploaded yet are uploaded. The
+// sync state is only saved after the URL file was saved.
+func syncGpodder(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errors.New("USAGE: sync")
+	}
+
+	client := gpodderClient
+	if len(client.Username) <= 0 || len(client.Password) <= 0 {
+		return errors.New("gpodder.user and gpodder.password are required")
+	}
+
+	if len(client.Device) <= 0 {
+		host, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+
+		client.Device = app.Name + "-" + strings.ToLower(host)
+		if len(app.Profile) > 0 {
+			client.Device += "-" + app.Profile
+		}
+	}
+
+	// Hold the lock until the sync state was saved.
+	if err := app.AcquireLock(); err != nil {
+		return err
+	}
+	defer app.ReleaseLock()
+
+	state, err := loadSyncState(strings.Join([]string{client.BaseURL, client.Username, client.Device}, " "))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	var storage *store.Store
+	err = app.ModifyStore(func(s *store.Store) (err error) {
+		storage = s
+		state.Timestamp, err = syncSubscriptions(ctx, &client, s, state)
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	state.Subscriptions = storage.URLs()
+	if err := state.save(); err != nil {
+		return err
+	}
+
+	if err := syncEpisodes(ctx, &client, storage, state); err != nil {
+		return err
+	}
+
+	return state.save()
+}
+
+// syncSubscriptions merges local and remote subscription changes and
+// returns the new server time.
+func syncSubscriptions(ctx context.Context, client *gpodder.Client, storage *store.Store, state *syncState) (int64, error) {
+	previous := make(map[string]bool)
+	for _, url := range state.Subscriptions {
+		previous[url] = true
+	}
+
+	local := make(map[string]bool)
+	var added, removed []string
+
+	for _, url := range storage.URLs() {
+		local[url] = true
+		if !previous[url] {
+			added = append(added, url)
+		}
+	}
+
+	for _, url := range state.Subscriptions {
+		if !local[url] {
+			removed = append(removed, url)
+		}
+	}
+
+	changes, err := client.Subscriptions(ctx, state.Timestamp)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, url := range changes.Add {
+		// Feeds removed locally since the last sync are not re-added.
+		if !storage.Contains(url) && !previous[url] {
+			storage.Add(url)
+			app.Logger.Printf("added %s\n", url)
+		}
+	}
+
+	// Feeds added locally since the last sync are not removed.
+	for _, url := range changes.Remove {
+		if storage.Contains(url) && previous[url] {
+			storage.Remove(url)
+			app.Logger.Printf("removed %s\n", url)
+		}
+	}
+
+	if len(added) <= 0 && len(removed) <= 0 {
+		return changes.Timestamp, nil
+	}
+
+	update, err := client.UploadChanges(ctx, added, removed)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, pair := range update.URLs {
+		if len(pair[1]) <= 0 {
+			app.Logger.Printf("server rejected %s\n", pair[0])
+		} else if pair[0] != pair[1] && storage.Move(pair[0], pair[1]) {
+			app.Logger.Printf("%s → %s\n", pair[0], pair[1])
+		}
+	}
+
+	return update.Timestamp, nil
+}
+
+// syncEpisodes uploads download actions for all downloaded episodes of
+// the given store which weren't uploaded yet.
+func syncEpisodes(ctx context.Context, client *gpodder.Client, storage *store.Store, state *syncState) error {
+	var actions []gpodder.EpisodeAction
+	for _, url := range storage.URLs() {
+		p := store.Podcast{URL: url, Options: storage.Options(url)}
+		if len(p.Options["name"]) <= 0 {
+			continue // Not fetched yet
+		}
+
+		dir, err := catcher.Dir(p)
+		if err != nil {
+			return err
+		}
+
+		_, episodes, err := catcher.History().Episodes(p)
+		if err != nil {
+			return err
+		}
+
+		for name, e := range episodes {
+			if len(e.URL) <= 0 || state.Uploaded[e.URL] {
+				continue
+			}
+
+			action := gpodder.EpisodeAction{Podcast: url, Episode: e.URL, Action: "download"}
+			if fi, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				action.Timestamp = fi.ModTime()
+			} else {
+				action.Timestamp = e.Published
+			}
+
+			actions = append(actions, action)
+		}
+	}
+
+	if len(actions) <= 0 {
+		return nil
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Timestamp.Before(actions[j].Timestamp)
+	})
+
+	if _, err := client.UploadActions(ctx, actions); err != nil {
+		return err
+	}
+
+	for _, a := range actions {
+		state.Uploaded[a.Episode] = true
+	}
+
+	app.Logger.Printf("uploaded %d episode actions\n", len(actions))
+	return nil
+}
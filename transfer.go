@@ -0,0 +1,364 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/nmeum/cpod/exchange"
+	"github.com/nmeum/cpod/internal/app"
+	"github.com/nmeum/cpod/podcatcher"
+	"github.com/nmeum/cpod/store"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// importCounts represents the result of an import.
+type importCounts struct {
+	added, skipped, duplicates int
+}
+
+// importSubs adds the subscriptions contained in the given files to the
+// store. The file name "-" refers to the standard input.
+func importSubs(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("f", "opml", "format of the files ("+strings.Join(exchange.Names(), ", ")+")")
+	dryRun := flags.Bool("dry-run", false, "only report which feeds would be imported")
+	category := flags.String("category", "", "only import feeds of the given folder or category")
+	interactive := flags.Bool("i", false, "ask before importing each feed")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: %s import [-f FORMAT] [-dry-run] [-category NAME] [-i] FILE...\n", app.Name)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if flags.NArg() <= 0 {
+		flags.Usage()
+		return errors.New("missing file")
+	}
+
+	for _, file := range flags.Args() {
+		if file == "-" && *interactive {
+			return errors.New("-i can't be used when reading from the standard input")
+		}
+	}
+
+	f, err := exchange.Lookup(*format)
+	if err != nil {
+		return err
+	}
+
+	subs, err := readSubs(f, flags.Args())
+	if err != nil {
+		return err
+	}
+
+	if len(*category) > 0 {
+		var selected []exchange.Subscription
+		for _, s := range subs {
+			if s.InCategory(*category) {
+				selected = append(selected, s)
+			}
+		}
+
+		subs = selected
+	}
+
+	var confirm func(exchange.Subscription) (bool, error)
+	if *interactive {
+		stdin := bufio.NewReader(os.Stdin)
+		confirm = func(s exchange.Subscription) (bool, error) {
+			return confirmSub(stdin, s)
+		}
+	}
+
+	if !*dryRun {
+		if err := app.AcquireLock(); err != nil {
+			return err
+		}
+		defer app.ReleaseLock()
+	}
+
+	storage, err := app.LoadStore()
+	if err != nil {
+		return err
+	}
+
+	c, err := addSubs(storage, subs, confirm, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "added"
+	if *dryRun {
+		verb = "to add"
+	}
+
+	fmt.Printf("%d %s, %d skipped, %d already subscribed\n", c.added, verb, c.skipped, c.duplicates)
+	return nil
+}
+
+// readSubs returns all subscriptions contained in the given files using
+// the given format.
+func readSubs(f exchange.Importer, files []string) ([]exchange.Subscription, error) {
+	var subs []exchange.Subscription
+	for _, file := range files {
+		r := os.Stdin
+		if file != "-" {
+			var err error
+			if r, err = os.Open(file); err != nil {
+				return nil, err
+			}
+			defer r.Close()
+		}
+
+		s, err := f.Import(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+
+		subs = append(subs, s...)
+	}
+
+	return subs, nil
+}
+
+// confirmSub asks the user whether the given subscription should be
+// imported.
+func confirmSub(r *bufio.Reader, s exchange.Subscription) (bool, error) {
+	if len(s.Title) > 0 {
+		fmt.Fprintf(os.Stderr, "Import %s <%s>? [y/N] ", s.Title, s.URL)
+	} else {
+		fmt.Fprintf(os.Stderr, "Import <%s>? [y/N] ", s.URL)
+	}
+
+	answer, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// addSubs adds the given subscriptions to the given store unless they
+// are already part of it. Options of the subscriptions are retained,
+// subscriptions with invalid options are ignored and played episodes
+// are skipped. If confirm is not nil, it is called for each
+// subscription before adding it. Unless dryRun is set, the feeds are
+// subscribed using the client as well.
+func addSubs(storage *store.Store, subs []exchange.Subscription, confirm func(exchange.Subscription) (bool, error), dryRun bool) (c importCounts, err error) {
+	for _, s := range subs {
+		s.URL = strings.TrimSpace(s.URL)
+		if err := podcatcher.Validate(s.URL, s.Options); err != nil {
+			app.Logger.Printf("skipping subscription %q: %s\n", s.URL, err)
+			c.skipped++
+			continue
+		} else if storage.Contains(s.URL) {
+			c.duplicates++
+			continue
+		}
+
+		if confirm != nil {
+			ok, err := confirm(s)
+			if err != nil {
+				return c, err
+			} else if !ok {
+				c.skipped++
+				continue
+			}
+		}
+
+		if !dryRun {
+			err := subscribe(s)
+			if errors.Is(err, podcatcher.ErrSubscribed) {
+				c.duplicates++
+				continue
+			} else if err != nil {
+				return c, err
+			}
+
+			fmt.Printf("added %s\n", s.URL)
+		} else {
+			fmt.Printf("would add %s\n", s.URL)
+		}
+
+		storage.Add(s.URL)
+		c.added++
+	}
+
+	return
+}
+
+// subscribe subscribes to the feed of the given subscription and marks
+// its played episodes as skipped. If there are any, the identifier of
+// the podcast is stored as option since the history is keyed by it.
+func subscribe(s exchange.Subscription) error {
+	var played []string
+	for _, e := range s.Episodes {
+		if e.Played && len(e.URL) > 0 {
+			played = append(played, strings.TrimSpace(e.URL))
+		}
+	}
+
+	p := store.Podcast{URL: s.URL, Options: s.Options}
+	if len(played) > 0 && len(p.Options["id"]) <= 0 {
+		opts := store.Options{"id": p.ID()}
+		for k, v := range p.Options {
+			opts[k] = v
+		}
+
+		p.Options = opts
+	}
+
+	if err := catcher.Subscribe(p.URL, p.Options); err != nil {
+		return err
+	} else if len(played) <= 0 {
+		return nil
+	}
+
+	history := catcher.History()
+	skipped, err := history.Skipped(p)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range played {
+		skipped[id] = true
+	}
+
+	return history.SetSkipped(p, skipped)
+}
+
+// exportEpisodes returns the downloaded and skipped episodes of the
+// given podcast. Skipped episodes are exported as played, the inverse
+// of subscribe. Skipped GUIDs are omitted since they aren't URLs.
+func exportEpisodes(p store.Podcast) ([]exchange.Episode, error) {
+	history := catcher.History()
+	skipped, err := history.Skipped(p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, downloaded, err := history.Episodes(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var episodes []exchange.Episode
+	for _, e := range downloaded {
+		if len(e.URL) > 0 {
+			played := skipped[e.URL]
+			episodes = append(episodes, exchange.Episode{URL: e.URL, Title: e.Title, Published: e.Published, Played: played})
+			delete(skipped, e.URL)
+		}
+	}
+
+	for id := range skipped {
+		if u, err := url.Parse(id); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			episodes = append(episodes, exchange.Episode{URL: id, Played: true})
+		}
+	}
+
+	sort.Slice(episodes, func(i, j int) bool {
+		if !episodes[i].Published.Equal(episodes[j].Published) {
+			return episodes[i].Published.After(episodes[j].Published)
+		}
+
+		return episodes[i].URL < episodes[j].URL
+	})
+
+	return episodes, nil
+}
+
+// exportSubs writes all subscriptions of the store to the given file or
+// the standard output. The metadata of the feeds is read from the cache
+// written when fetching them, the tags of the feeds are exported as
+// categories. Downloaded and skipped episodes are exported as well.
+func exportSubs(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("f", "opml", "format of the file ("+strings.Join(exchange.Names(), ", ")+")")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "USAGE: %s export [-f FORMAT] [FILE]\n", app.Name)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	} else if flags.NArg() > 1 {
+		flags.Usage()
+		return errors.New("too many arguments")
+	}
+
+	f, err := exchange.Lookup(*format)
+	if err != nil {
+		return err
+	}
+
+	storage, err := app.LoadStore()
+	if err != nil {
+		return err
+	}
+
+	var subs []exchange.Subscription
+	for _, u := range storage.URLs() {
+		p := store.Podcast{URL: u, Options: storage.Options(u)}
+
+		m, err := store.LoadMetadata(cacheDir(p))
+		if err != nil && !os.IsNotExist(err) {
+			app.Logger.Printf("%s: %s\n", p.URL, err)
+		}
+
+		episodes, err := exportEpisodes(p)
+		if err != nil {
+			return err
+		}
+
+		subs = append(subs, exchange.Subscription{
+			URL:        p.URL,
+			Title:      m.Title,
+			Link:       m.Link,
+			Categories: p.Tags(),
+			Options:    p.Options,
+			Episodes:   episodes,
+		})
+	}
+
+	if flags.NArg() <= 0 || flags.Arg(0) == "-" {
+		return f.Export(os.Stdout, subs)
+	}
+
+	file, err := os.Create(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if err := f.Export(file, subs); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+// indexPage is the web interface served by the daemon. It only uses
+// the HTTP API and doesn't require any external resources.
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>cpod</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 1em auto; padding: 0 1em; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: 0.2em 0.5em; border-bottom: 1px solid #ddd; }
+input[type=url] { width: 30em; max-width: 100%; }
+#error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>cpod</h1>
+<p id="error"></p>
+
+<h2>Status</h2>
+<p>Last refresh: <span id="last">never</span> <button id="refresh">Refresh now</button></p>
+<table id="downloads"></table>
+
+<h2>Subscriptions</h2>
+<form id="add">
+<input type="url" id="url" placeholder="Feed URL" required>
+<button type="submit">Subscribe</button>
+</form>
+<table id="subscriptions"></table>
+
+<h2>Episodes</h2>
+<div id="episodes"></div>
+
+<script>
+function api(method, path, body) {
+	var opts = { method: method };
+	if (method !== "GET") {
+		opts.body = JSON.stringify(body === undefined ? {} : body);
+		opts.headers = { "Content-Type": "application/json" };
+	}
+
+	return fetch(path, opts).then(function (resp) {
+		return resp.json().then(function (data) {
+			if (!resp.ok) {
+				throw new Error(data.error);
+			}
+			return data;
+		});
+	});
+}
+
+function showError(err) {
+	document.getElementById("error").textContent = err ? err.message : "";
+}
+
+function row(table, cells) {
+	var tr = table.insertRow();
+	cells.forEach(function (c) {
+		var td = tr.insertCell();
+		if (typeof c === "string") {
+			td.textContent = c;
+		} else {
+			td.appendChild(c);
+		}
+	});
+}
+
+function loadStatus() {
+	return api("GET", "/api/status").then(function (r) {
+		var last = new Date(r.last_refresh);
+		document.getElementById("last").textContent =
+			last.getFullYear() > 1 ? last.toLocaleString() : "never";
+
+		var table = document.getElementById("downloads");
+		table.innerHTML = "";
+		r.queue.forEach(function (url) {
+			row(table, ["queued", url]);
+		});
+		r.downloads.forEach(function (d) {
+			row(table, ["downloading", d.podcast + ": " + d.episode]);
+		});
+	});
+}
+
+function loadSubscriptions() {
+	return api("GET", "/api/subscriptions").then(function (subs) {
+		var table = document.getElementById("subscriptions");
+		table.innerHTML = "";
+		subs.forEach(function (s) {
+			var btn = document.createElement("button");
+			btn.textContent = "Unsubscribe";
+			btn.onclick = function () {
+				api("DELETE", "/api/subscriptions?url=" + encodeURIComponent(s.url))
+					.then(loadSubscriptions).then(showError).catch(showError);
+			};
+			row(table, [s.url, btn]);
+		});
+	});
+}
+
+function loadEpisodes() {
+	return api("GET", "/api/episodes").then(function (podcasts) {
+		var div = document.getElementById("episodes");
+		div.innerHTML = "";
+		podcasts.forEach(function (p) {
+			var h = document.createElement("h3");
+			h.textContent = p.podcast;
+			div.appendChild(h);
+
+			var table = document.createElement("table");
+			p.episodes.forEach(function (e) {
+				var a = document.createElement("a");
+				a.href = e.path;
+				a.textContent = e.name;
+				row(table, [a, new Date(e.modified).toLocaleString()]);
+			});
+			div.appendChild(table);
+		});
+	});
+}
+
+document.getElementById("add").onsubmit = function (ev) {
+	ev.preventDefault();
+	var input = document.getElementById("url");
+	api("POST", "/api/subscriptions", { url: input.value }).then(function () {
+		input.value = "";
+		return loadSubscriptions();
+	}).then(showError).catch(showError);
+};
+
+document.getElementById("refresh").onclick = function () {
+	api("POST", "/api/refresh").then(loadStatus).then(showError).catch(showError);
+};
+
+Promise.all([loadStatus(), loadSubscriptions(), loadEpisodes()]).catch(showError);
+setInterval(function () {
+	Promise.all([loadStatus(), loadEpisodes()]).catch(showError);
+}, 5000);
+</script>
+</body>
+</html>
+`
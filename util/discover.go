@@ -0,0 +1,122 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"golang.org/x/net/html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Media types of feeds referenced by HTML pages.
+var feedTypes = map[string]bool{
+	"application/rss+xml":  true,
+	"application/atom+xml": true,
+}
+
+// Alternate represents a feed referenced by an HTML page.
+type Alternate struct {
+	// Title of the feed, might be empty.
+	Title string
+
+	// Media type of the feed.
+	Type string
+
+	// Absolute URL of the feed.
+	URL string
+}
+
+// IsHTML returns true if a document with the given content type and
+// body is an HTML page. If the content type is missing or generic, the
+// body is examined.
+func IsHTML(contentType string, body []byte) bool {
+	mtype, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		switch mtype {
+		case "text/html", "application/xhtml+xml":
+			return true
+		case "text/plain", "application/octet-stream":
+		default:
+			return false
+		}
+	}
+
+	return strings.HasPrefix(http.DetectContentType(body), "text/html")
+}
+
+// Alternates returns the feeds referenced by the link elements with an
+// alternate relation of the given HTML page. Relative URLs are resolved
+// against the given base URL.
+func Alternates(r io.Reader, base *url.URL) ([]Alternate, error) {
+	var feeds []Alternate
+	seen := make(map[string]bool)
+
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return feeds, nil
+			}
+
+			return nil, z.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "link" || !hasAttr {
+				continue
+			}
+
+			attrs := make(map[string]string)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[string(key)] = strings.TrimSpace(string(val))
+			}
+
+			feed, ok := alternate(attrs, base)
+			if ok && !seen[feed.URL] {
+				seen[feed.URL] = true
+				feeds = append(feeds, feed)
+			}
+		}
+	}
+}
+
+// alternate converts the attributes of a link element to a feed. If
+// the element doesn't reference a feed, false is returned.
+func alternate(attrs map[string]string, base *url.URL) (Alternate, bool) {
+	var isAlternate bool
+	for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+		if rel == "alternate" {
+			isAlternate = true
+		}
+	}
+
+	mtype, _, err := mime.ParseMediaType(attrs["type"])
+	if !isAlternate || err != nil || !feedTypes[mtype] || len(attrs["href"]) <= 0 {
+		return Alternate{}, false
+	}
+
+	ref, err := url.Parse(attrs["href"])
+	if err != nil {
+		return Alternate{}, false
+	}
+
+	return Alternate{attrs["title"], mtype, base.ResolveReference(ref).String()}, true
+}
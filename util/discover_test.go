@@ -0,0 +1,76 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsHTML(t *testing.T) {
+	tests := []struct {
+		ctype string
+		body  string
+		html  bool
+	}{
+		{"text/html; charset=utf-8", "", true},
+		{"application/rss+xml", "<html></html>", false},
+		{"", "<!DOCTYPE html><html></html>", true},
+		{"text/plain", "<?xml version=\"1.0\"?><rss></rss>", false},
+	}
+
+	for _, test := range tests {
+		if IsHTML(test.ctype, []byte(test.body)) != test.html {
+			t.Fatalf("Expected %v for %q - got %v", test.html, test.ctype, !test.html)
+		}
+	}
+}
+
+func TestAlternates(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head>
+	<link rel="stylesheet" type="text/css" href="/style.css">
+	<link rel="alternate" type="application/rss+xml" title="MP3" href="/feed/mp3">
+	<link rel="Alternate" type="application/atom+xml; charset=utf-8" href="https://cdn.example.org/feed.atom" />
+	<link rel="alternate" type="application/rss+xml" href="feed/mp3">
+	<link rel="alternate" hreflang="de" href="/de/">
+</head>
+<body></body>
+</html>`
+
+	base, err := url.Parse("http://example.com/podcast/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feeds, err := Alternates(strings.NewReader(page), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Alternate{
+		{"MP3", "application/rss+xml", "http://example.com/feed/mp3"},
+		{"", "application/atom+xml", "https://cdn.example.org/feed.atom"},
+		{"", "application/rss+xml", "http://example.com/podcast/feed/mp3"},
+	}
+
+	if !reflect.DeepEqual(feeds, expected) {
+		t.Fatalf("Expected %v - got %v", expected, feeds)
+	}
+}
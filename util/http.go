@@ -16,6 +16,7 @@
 package util
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -29,22 +30,29 @@ import (
 	"time"
 )
 
-const (
-	// Number of times a failed HTTP request is retried.
-	retry = 3
+// Number of maximal allowed redirects.
+const maxRedirects = 10
 
-	// Number of maximal allowed redirects.
-	maxRedirects = 10
+// Options of HTTP requests, they should only be changed before the
+// first request is sent.
+var (
+	// Number of times a failed HTTP request is retried.
+	Retries = 3
 
 	// HTTP User-Agent.
-	useragent = "cpod"
+	UserAgent = "cpod"
+
+	// Transport used for all requests, e.g. its ResponseHeaderTimeout
+	// limits the time to wait for a server.
+	Transport = http.DefaultTransport.(*http.Transport).Clone()
 )
 
 // Get performs a HTTP GET request, just like http.get, however, it has
 // a few handy extra features: I adds a User-Agent header and it retries
-// a failed get request if the error was a temporary one.
-func Get(uri string) (resp *http.Response, err error) {
-	req, err := http.NewRequest("GET", uri, nil)
+// a failed get request if the error was a temporary one. The request is
+// aborted when the given context is canceled.
+func Get(ctx context.Context, uri string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
 	if err != nil {
 		return
 	}
@@ -52,10 +60,17 @@ func Get(uri string) (resp *http.Response, err error) {
 	return doReq(req)
 }
 
+// Do performs the given HTTP request with the same extra features as
+// Get, the request is aborted when its context is canceled.
+func Do(req *http.Request) (*http.Response, error) {
+	return doReq(req)
+}
+
 // GetFile downloads the file from the given uri and stores it in the
-// specified target directory. If a download was interrupted previously
-// GetFile is able to resume it.
-func GetFile(uri, target string) (fp string, err error) {
+// specified target directory. If a download was interrupted previously,
+// e.g. because the given context was canceled, GetFile is able to
+// resume it.
+func GetFile(ctx context.Context, uri, target string) (fp string, err error) {
 	if err = os.MkdirAll(target, 0755); err != nil {
 		return
 	}
@@ -68,11 +83,11 @@ func GetFile(uri, target string) (fp string, err error) {
 	fp = filepath.Join(target, fn)
 	partPath := fmt.Sprintf("%s.part", fp)
 	if _, err = os.Open(partPath); os.IsNotExist(err) {
-		if err = newGet(uri, partPath); err != nil {
+		if err = newGet(ctx, uri, partPath); err != nil {
 			return
 		}
 	} else {
-		if err = resumeGet(uri, partPath); err != nil {
+		if err = resumeGet(ctx, uri, partPath); err != nil {
 			return
 		}
 	}
@@ -86,13 +101,13 @@ func GetFile(uri, target string) (fp string, err error) {
 
 // resumeGet resumes an canceled download started by the newGet
 // function.
-func resumeGet(uri, target string) error {
+func resumeGet(ctx context.Context, uri, target string) error {
 	fi, err := os.Stat(target)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("GET", uri, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
 	if err != nil {
 		return err
 	}
@@ -107,7 +122,7 @@ func resumeGet(uri, target string) error {
 
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusPartialContent {
-		return newGet(uri, target)
+		return newGet(ctx, uri, target)
 	}
 
 	file, err := os.OpenFile(target, os.O_WRONLY|os.O_APPEND, 0644)
@@ -125,8 +140,8 @@ func resumeGet(uri, target string) error {
 
 // newGet starts a new file download, if the download wasn't completed
 // it can be resumed later on using the resumeGet function.
-func newGet(uri, target string) error {
-	resp, err := Get(uri)
+func newGet(ctx context.Context, uri, target string) error {
+	resp, err := Get(ctx, uri)
 	if err != nil {
 		return err
 	}
@@ -169,13 +184,17 @@ func filename(uri string) (fn string, err error) {
 // temporary error on layer 4 is encountered. Furthermore, it also ensure that
 // headers remain the same after a redirect and it adds a User-Agent header.
 func doReq(req *http.Request) (resp *http.Response, err error) {
-	req.Header.Add("User-Agent", useragent)
+	req.Header.Add("User-Agent", UserAgent)
 	client := headerClient(req.Header)
 
-	for i := 1; i <= retry; i++ {
+	for i := 1; i <= Retries; i++ {
 		resp, err = client.Do(req)
 		if nerr, ok := err.(net.Error); ok && (nerr.Temporary() || nerr.Timeout()) {
-			time.Sleep(time.Duration(i*3) * time.Second)
+			select {
+			case <-time.After(time.Duration(i*3) * time.Second):
+			case <-req.Context().Done():
+				return
+			}
 		} else {
 			break
 		}
@@ -196,5 +215,5 @@ func headerClient(headers http.Header) *http.Client {
 		return nil
 	}
 
-	return &http.Client{CheckRedirect: redirectFunc}
+	return &http.Client{CheckRedirect: redirectFunc, Transport: Transport}
 }
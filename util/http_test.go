@@ -16,6 +16,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -60,7 +61,7 @@ func TestGet(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(th))
 	defer ts.Close()
 
-	resp, err := Get(ts.URL)
+	resp, err := Get(context.Background(), ts.URL)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -86,7 +87,7 @@ func TestGetFile1(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(th))
 	defer ts.Close()
 
-	fp, err := GetFile(ts.URL, os.TempDir())
+	fp, err := GetFile(context.Background(), ts.URL, os.TempDir())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -17,35 +17,135 @@ package util
 
 import (
 	"errors"
+	"fmt"
 	"html"
+	"io/ioutil"
 	"os"
-	"os/signal"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 	"unicode"
 )
 
-// Lock creates a lockfile at the given path and creates a signal
-// handler which removes the lockfile on interrupt or kill.
-func Lock(path string) (err error) {
-	_, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0444)
+// Interval in which LockWait retries to acquire a lock.
+const lockInterval = 250 * time.Millisecond
+
+// Lockfile represents an acquired lock.
+type Lockfile struct {
+	// Path of the lockfile.
+	path string
+
+	// Open lockfile, the lock is held as long as it isn't closed.
+	file *os.File
+}
+
+// Lock acquires an advisory lock (see flock(2)) on a lockfile at the
+// given path and writes the PID and hostname of the current process
+// to it. If the lock is held by another process an error satisfying
+// os.IsExist is returned. Since the operating system releases the lock
+// when a process terminates, lockfiles left behind by crashed processes
+// are reused.
+func Lock(path string) (l *Lockfile, err error) {
+	for l == nil {
+		l, err = tryLock(path)
+		if err != nil {
+			return
+		}
+	}
+
+	hostname, err := os.Hostname()
 	if err != nil {
-		return
+		hostname = "unknown"
 	}
 
-	// Setup unlock handler
-	ch := make(chan os.Signal, 1)
-	go func() {
-		<-ch // Block until signal is received
-		os.Remove(path)
-		os.Exit(2)
-	}()
-	signal.Notify(ch, os.Interrupt, os.Kill)
+	if err = l.file.Truncate(0); err == nil {
+		_, err = fmt.Fprintf(l.file, "%d %s\n", os.Getpid(), hostname)
+	}
+
+	if err != nil {
+		l.Unlock()
+		return nil, err
+	}
 
 	return
 }
 
+// LockWait behaves like Lock but if the lock is held by another process
+// it waits until the lock is released or the given timeout expired.
+func LockWait(path string, timeout time.Duration) (*Lockfile, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		l, err := Lock(path)
+		if !os.IsExist(err) || !time.Now().Before(deadline) {
+			return l, err
+		}
+
+		time.Sleep(lockInterval)
+	}
+}
+
+// LockOwner returns the PID and hostname of the process which created
+// the lockfile at the given path as written by Lock.
+func LockOwner(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Unlock removes the lockfile and releases the lock.
+func (l *Lockfile) Unlock() error {
+	// Remove before closing, otherwise another process might
+	// acquire the lock on the file which is then removed by us.
+	err := os.Remove(l.path)
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// tryLock attempts to acquire the lock once. If the lockfile was
+// removed by the previous owner while the lock was being acquired
+// both return values are nil and the caller should try again.
+func tryLock(path string) (*Lockfile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		file.Close()
+		return nil, &os.PathError{Op: "lock", Path: path, Err: os.ErrExist}
+	} else if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	pfi, err := os.Stat(path)
+	if err != nil || !os.SameFile(fi, pfi) {
+		file.Close()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
+	return &Lockfile{path, file}, nil
+}
+
 // Escape escapes the given data to make sure it is safe to use it as a
 // filename. It also replaces spaces and other seperation characters
 // with the '-' character. It returns an error if the escaped string is
@@ -19,38 +19,81 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLock1(t *testing.T) {
-	file, err := ioutil.TempFile(os.TempDir(), "testLock")
+	lockPath := filepath.Join(os.TempDir(), "lockTest1")
+	lock, err := Lock(lockPath)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	defer file.Close()
-	fi, err := file.Stat()
+	if _, err := Lock(lockPath); !os.IsExist(err) {
+		t.Fatalf("Expected lock to be held - got %v", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatal("Lockfile wasn't removed")
+	}
+}
+
+func TestLock2(t *testing.T) {
+	file, err := ioutil.TempFile(os.TempDir(), "testLock")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	lockPath := filepath.Join(os.TempDir(), fi.Name())
-	if err := Lock(lockPath); !os.IsExist(err) {
-		t.Fail()
+	// Stale lockfile, no process holds a lock on it.
+	lockPath := file.Name()
+	file.Close()
+
+	lock, err := Lock(lockPath)
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer lock.Unlock()
 
-	if err := os.Remove(lockPath); err != nil {
+	owner, err := LockOwner(lockPath)
+	if err != nil {
 		t.Fatal(err)
 	}
+
+	pid := strconv.Itoa(os.Getpid())
+	if !strings.HasPrefix(owner, pid+" ") {
+		t.Fatalf("Expected owner %q - got %q", pid, owner)
+	}
 }
 
-func TestLock2(t *testing.T) {
-	lockPath := filepath.Join(os.TempDir(), "lockTest")
-	if err := Lock(lockPath); os.IsExist(err) {
+func TestLockWait(t *testing.T) {
+	lockPath := filepath.Join(os.TempDir(), "lockTestWait")
+	lock, err := Lock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LockWait(lockPath, 100*time.Millisecond); !os.IsExist(err) {
+		t.Fatalf("Expected lock to be held - got %v", err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		lock.Unlock()
+	}()
+
+	waited, err := LockWait(lockPath, 5*time.Second)
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	if err := os.Remove(lockPath); err != nil {
+	if err := waited.Unlock(); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -0,0 +1,357 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package websub implements a subscriber for WebSub hubs.
+// See also: https://www.w3.org/TR/websub/
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"github.com/nmeum/cpod/util"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum size of a notification body which is verified.
+const maxBodySize = 16 << 20
+
+// Hash functions which can be used to sign notifications.
+var signatures = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// Subscriber subscribes to topics at WebSub hubs and receives their
+// notifications. A Subscriber is an http.Handler which needs to be
+// reachable by the hubs at the callback URL.
+type Subscriber struct {
+	// Base URL of the callbacks, the identifier of a subscription
+	// is appended to it.
+	callback string
+
+	// Function called with the topic of a verified notification.
+	notify func(topic string)
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+}
+
+// subscription represents a subscription to a topic at a hub.
+type subscription struct {
+	// URL of the hub.
+	hub string
+
+	// URL of the topic.
+	topic string
+
+	// Secret used by the hub to sign notifications, empty for hubs
+	// which aren't reached using HTTPS.
+	secret string
+
+	// Mode of the last request sent to the hub.
+	mode string
+
+	// Time the last request was sent to the hub.
+	requested time.Time
+
+	// Time the subscription expires, zero if it wasn't verified.
+	expires time.Time
+}
+
+// New returns a new Subscriber whose callbacks are located below the
+// given base URL. The given function is called with the topic of each
+// verified notification.
+func New(callback string, notify func(topic string)) *Subscriber {
+	if !strings.HasSuffix(callback, "/") {
+		callback += "/"
+	}
+
+	return &Subscriber{
+		callback: callback,
+		notify:   notify,
+		subs:     make(map[string]*subscription),
+	}
+}
+
+// Subscribe requests a subscription to the given topic at the given
+// hub. The subscription is only active after the hub verified it. An
+// existing subscription to the topic at the same hub is renewed. A
+// secret for signing notifications is only sent to HTTPS hubs.
+func (s *Subscriber) Subscribe(ctx context.Context, hub, topic string) error {
+	s.mu.Lock()
+	id, sub := s.lookup(topic)
+	if sub != nil && sub.hub == hub {
+		sub.mode = "subscribe"
+		sub.requested = time.Now()
+		s.mu.Unlock()
+
+		return s.request(ctx, id, sub)
+	} else if sub != nil {
+		delete(s.subs, id)
+	}
+	s.mu.Unlock()
+
+	id, err := random(16)
+	if err != nil {
+		return err
+	}
+
+	// The secret would be exposed to anyone on the path to a plain
+	// HTTP hub, notifications of such hubs aren't authenticated.
+	var secret string
+	if u, err := url.Parse(hub); err == nil && u.Scheme == "https" {
+		if secret, err = random(32); err != nil {
+			return err
+		}
+	}
+
+	sub = &subscription{hub, topic, secret, "subscribe", time.Now(), time.Time{}}
+	s.mu.Lock()
+	s.subs[id] = sub
+	s.mu.Unlock()
+
+	return s.request(ctx, id, sub)
+}
+
+// Unsubscribe requests to end the subscription to the given topic.
+func (s *Subscriber) Unsubscribe(ctx context.Context, topic string) error {
+	s.mu.Lock()
+	id, sub := s.lookup(topic)
+	if sub == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("not subscribed to %q", topic)
+	}
+
+	sub.mode = "unsubscribe"
+	sub.requested = time.Now()
+	s.mu.Unlock()
+
+	return s.request(ctx, id, sub)
+}
+
+// lookup returns the identifier and the subscription of the given
+// topic. If there is none, the returned subscription is nil. The mutex
+// needs to be held by the caller.
+func (s *Subscriber) lookup(topic string) (string, *subscription) {
+	for id, sub := range s.subs {
+		if sub.topic == topic {
+			return id, sub
+		}
+	}
+
+	return "", nil
+}
+
+// Due returns true if the subscription to the given topic needs to be
+// requested (again). This is the case if there is no subscription, if
+// it expires within the given duration or if it wasn't verified within
+// the given duration.
+func (s *Subscriber) Due(topic string, margin time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, sub := s.lookup(topic)
+	if sub == nil || sub.mode != "subscribe" {
+		return true
+	}
+
+	now := time.Now()
+	if sub.expires.Sub(now) >= margin {
+		return false
+	}
+
+	// Don't repeat requests the hub didn't verify (yet) too often.
+	return now.Sub(sub.requested) > margin
+}
+
+// Topics returns the topics of all subscriptions.
+func (s *Subscriber) Topics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var topics []string
+	for _, sub := range s.subs {
+		if sub.mode == "subscribe" {
+			topics = append(topics, sub.topic)
+		}
+	}
+
+	return topics
+}
+
+// request sends the request for the given subscription to its hub.
+func (s *Subscriber) request(ctx context.Context, id string, sub *subscription) error {
+	s.mu.Lock()
+	form := url.Values{
+		"hub.mode":     {sub.mode},
+		"hub.topic":    {sub.topic},
+		"hub.callback": {s.callback + id},
+	}
+	if sub.mode == "subscribe" && len(sub.secret) > 0 {
+		form.Set("hub.secret", sub.secret)
+	}
+	hub, mode := sub.hub, sub.mode
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := util.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("hub %q rejected %s request: %s", hub, mode, resp.Status)
+	}
+
+	return nil
+}
+
+// ServeHTTP handles verification requests and notifications sent by
+// hubs. The path of the request has to be the subscription identifier.
+func (s *Subscriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch r.Method {
+	case "GET":
+		s.verify(w, r, id)
+	case "POST":
+		s.receive(w, r, id)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify answers a verification of intent or a denial of a hub.
+func (s *Subscriber) verify(w http.ResponseWriter, r *http.Request, id string) {
+	query := r.URL.Query()
+	mode, topic := query.Get("hub.mode"), query.Get("hub.topic")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok || sub.topic != topic {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case mode == "denied":
+		sub.expires = time.Time{}
+		fmt.Fprint(w, "ok")
+		return
+	case mode != sub.mode:
+		http.NotFound(w, r)
+		return
+	case mode == "unsubscribe":
+		delete(s.subs, id)
+	case mode == "subscribe":
+		lease, err := strconv.Atoi(query.Get("hub.lease_seconds"))
+		if err != nil || lease <= 0 {
+			http.Error(w, "invalid lease", http.StatusBadRequest)
+			return
+		}
+
+		sub.expires = time.Now().Add(time.Duration(lease) * time.Second)
+	}
+
+	io.WriteString(w, query.Get("hub.challenge"))
+}
+
+// receive handles a notification. Notifications which aren't signed
+// correctly are acknowledged but ignored, as required by the standard.
+// Notifications of subscriptions without a secret are always accepted.
+func (s *Subscriber) receive(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	sub, ok := s.subs[id]
+	var topic, secret string
+	if ok {
+		topic, secret = sub.topic, sub.secret
+		ok = sub.mode == "subscribe"
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	if len(secret) <= 0 || validSignature(r.Header.Get("X-Hub-Signature"), secret, body) {
+		s.notify(topic)
+	}
+}
+
+// validSignature returns true if the given X-Hub-Signature header is
+// a valid signature of the given body.
+func validSignature(header, secret string, body []byte) bool {
+	i := strings.Index(header, "=")
+	if i < 0 {
+		return false
+	}
+
+	fn, ok := signatures[header[0:i]]
+	if !ok {
+		return false
+	}
+
+	sig, err := hex.DecodeString(header[i+1:])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(fn, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// random returns a random hex string of n bytes.
+func random(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
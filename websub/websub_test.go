@@ -0,0 +1,215 @@
+// Copyright (C) 2013-2015 Sören Tempel
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package websub
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/nmeum/cpod/util"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const topic = "http://example.com/feed.rss"
+
+// hub is a minimal WebSub hub recording the last subscription request.
+type hub struct {
+	form url.Values
+}
+
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	h.form = r.PostForm
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// setup returns a subscriber whose callbacks are served by a test
+// server, a fake HTTPS hub and a channel receiving notified topics.
+func setup(t *testing.T) (*Subscriber, *hub, *httptest.Server, chan string) {
+	h := new(hub)
+	hs := httptest.NewTLSServer(h)
+	t.Cleanup(hs.Close)
+	util.Transport.TLSClientConfig = hs.Client().Transport.(*http.Transport).TLSClientConfig
+
+	return subscribe(t, h, hs.URL)
+}
+
+// subscribe returns a subscriber subscribed at the given hub, see setup.
+func subscribe(t *testing.T, h *hub, hubURL string) (*Subscriber, *hub, *httptest.Server, chan string) {
+
+	notified := make(chan string, 1)
+	mux := http.NewServeMux()
+	cs := httptest.NewServer(mux)
+	t.Cleanup(cs.Close)
+
+	s := New(cs.URL+"/websub", func(topic string) { notified <- topic })
+	mux.Handle("/websub/", http.StripPrefix("/websub", s))
+
+	if err := s.Subscribe(context.Background(), hubURL, topic); err != nil {
+		t.Fatal(err)
+	}
+
+	return s, h, cs, notified
+}
+
+func verify(t *testing.T, h *hub, mode, lease string) (*http.Response, string) {
+	query := url.Values{
+		"hub.mode":          {mode},
+		"hub.topic":         {topic},
+		"hub.challenge":     {"foobar"},
+		"hub.lease_seconds": {lease},
+	}
+
+	resp, err := http.Get(h.form.Get("hub.callback") + "?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, string(body)
+}
+
+func notify(t *testing.T, h *hub, body, secret string) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+
+	req, err := http.NewRequest("POST", h.form.Get("hub.callback"), strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected %d - got %d", http.StatusAccepted, resp.StatusCode)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s, h, _, _ := setup(t)
+	if h.form.Get("hub.mode") != "subscribe" || h.form.Get("hub.topic") != topic {
+		t.Fatalf("Unexpected request %v", h.form)
+	}
+
+	if s.Due(topic, time.Hour) {
+		t.Fatal("Unverified subscription was requested again immediately")
+	}
+
+	resp, body := verify(t, h, "subscribe", "86400")
+	if resp.StatusCode != http.StatusOK || body != "foobar" {
+		t.Fatalf("Expected %q - got %q", "foobar", body)
+	}
+
+	if s.Due(topic, time.Hour) {
+		t.Fatal("Verified subscription is due")
+	}
+
+	if s.Due(topic, 48*time.Hour) {
+		t.Fatal("Expiring subscription was requested again immediately")
+	}
+
+	for _, sub := range s.subs {
+		sub.requested = time.Now().Add(-72 * time.Hour)
+	}
+
+	if !s.Due(topic, 48*time.Hour) {
+		t.Fatal("Expiring subscription is not due")
+	}
+}
+
+func TestVerifyMismatch(t *testing.T) {
+	_, h, _, _ := setup(t)
+	if resp, _ := verify(t, h, "unsubscribe", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected %d - got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	_, h, _, notified := setup(t)
+	verify(t, h, "subscribe", "86400")
+
+	notify(t, h, "<rss></rss>", "wrong secret")
+	select {
+	case <-notified:
+		t.Fatal("Notification with invalid signature was accepted")
+	default:
+	}
+
+	notify(t, h, "<rss></rss>", h.form.Get("hub.secret"))
+	select {
+	case got := <-notified:
+		if got != topic {
+			t.Fatalf("Expected %q - got %q", topic, got)
+		}
+	default:
+		t.Fatal("Notification wasn't received")
+	}
+}
+
+func TestPlainHub(t *testing.T) {
+	h := new(hub)
+	hs := httptest.NewServer(h)
+	t.Cleanup(hs.Close)
+
+	_, h, _, notified := subscribe(t, h, hs.URL)
+	if _, ok := h.form["hub.secret"]; ok {
+		t.Fatal("Secret was sent to a plain HTTP hub")
+	}
+
+	verify(t, h, "subscribe", "86400")
+	notify(t, h, "<rss></rss>", "")
+	select {
+	case <-notified:
+	default:
+		t.Fatal("Notification wasn't received")
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	s, h, _, _ := setup(t)
+	verify(t, h, "subscribe", "86400")
+
+	callback := h.form.Get("hub.callback")
+	if err := s.Unsubscribe(context.Background(), topic); err != nil {
+		t.Fatal(err)
+	}
+
+	if h.form.Get("hub.mode") != "unsubscribe" || h.form.Get("hub.callback") != callback {
+		t.Fatalf("Unexpected request %v", h.form)
+	}
+
+	verify(t, h, "unsubscribe", "")
+	if len(s.Topics()) != 0 {
+		t.Fatalf("Expected no topics - got %q", s.Topics())
+	}
+}